@@ -0,0 +1,237 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+package consistent
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrCapacity is returned when adding an element to a full Anchor.
+	ErrCapacity = errors.New("capacity exceeded")
+	// ErrInvalidCapacity is returned by NewAnchor when capacity is not positive.
+	ErrInvalidCapacity = errors.New("capacity must be positive")
+)
+
+// Anchor implements AnchorHash ("AnchorHash: A Scalable Consistent Hash" by
+// Mendelson et al.). It hashes keys over a fixed number of buckets, the
+// capacity, of which the elements occupy a subset. Removing an element only
+// moves its own keys, and lookups stay fast however many elements have
+// been removed.
+//
+// Bucket assignment depends on the order of additions and removals, so
+// processes that must agree on the placement have to apply the same changes
+// in the same order.
+type Anchor struct {
+	mu      sync.RWMutex
+	hasher  Hasher64
+	a       []int // a[b] is the number of working buckets when b was removed, 0 if working
+	w       []int // w[:n] lists the working buckets
+	l       []int // l[b] is the last position of b in w
+	k       []int // k[b] is the bucket that replaced b in w
+	r       []int // stack of removed buckets
+	n       int
+	buckets map[string]int
+	elems   []*Element // elems[b] is the element of bucket b
+}
+
+// NewAnchor creates a new Anchor object with room for capacity elements that
+// hashes keys with h.
+func NewAnchor(h Hasher64, capacity int) (*Anchor, error) {
+	if capacity < 1 {
+		return nil, ErrInvalidCapacity
+	}
+	x := &Anchor{
+		hasher:  h,
+		a:       make([]int, capacity),
+		w:       make([]int, capacity),
+		l:       make([]int, capacity),
+		k:       make([]int, capacity),
+		r:       make([]int, 0, capacity),
+		buckets: make(map[string]int),
+		elems:   make([]*Element, capacity),
+	}
+	for b := capacity - 1; b >= 0; b-- {
+		x.w[b], x.l[b], x.k[b] = b, b, b
+		x.a[b] = b
+		x.r = append(x.r, b)
+	}
+	return x, nil
+}
+
+// Capacity returns the maximum number of elements.
+func (x *Anchor) Capacity() int {
+	return len(x.a)
+}
+
+// Add inserts an element. It does nothing when the Anchor is full; use TryAdd
+// to detect that.
+func (x *Anchor) Add(key string, value interface{}) {
+	x.TryAdd(key, value)
+}
+
+// AddReplicas is the same as Add: AnchorHash has no virtual nodes.
+func (x *Anchor) AddReplicas(key string, value interface{}, replica int) {
+	x.TryAdd(key, value)
+}
+
+// TryAdd inserts an element, or returns ErrCapacity when the Anchor is full.
+// Adding an existing key updates its value in place.
+func (x *Anchor) TryAdd(key string, value interface{}) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.add(key, value)
+}
+
+// need x.mu.Lock() before calling
+func (x *Anchor) add(key string, value interface{}) error {
+	elem := &Element{Key: key, Value: value, Replica: 1, Weight: 1}
+	if b, ok := x.buckets[key]; ok {
+		x.elems[b] = elem
+		return nil
+	}
+	if len(x.r) == 0 {
+		return ErrCapacity
+	}
+	b := x.r[len(x.r)-1]
+	x.r = x.r[:len(x.r)-1]
+	x.a[b] = 0
+	x.l[x.w[x.n]] = x.n
+	x.w[x.l[b]] = b
+	x.k[b] = b
+	x.n++
+	x.buckets[key] = b
+	x.elems[b] = elem
+	return nil
+}
+
+// Remove removes an element, freeing its bucket.
+func (x *Anchor) Remove(key string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.remove(key)
+}
+
+// need x.mu.Lock() before calling
+func (x *Anchor) remove(key string) {
+	b, ok := x.buckets[key]
+	if !ok {
+		return
+	}
+	x.r = append(x.r, b)
+	x.n--
+	x.a[b] = x.n
+	x.w[x.l[b]] = x.w[x.n]
+	x.l[x.w[x.n]] = x.l[b]
+	x.k[b] = x.w[x.n]
+	delete(x.buckets, key)
+	x.elems[b] = nil
+}
+
+// Set sets all the elements. Elements that stay keep their buckets; new
+// elements are added in key order until the Anchor is full. Use TrySet to
+// detect that.
+func (x *Anchor) Set(kvs map[string]interface{}) {
+	x.TrySet(kvs)
+}
+
+// TrySet is like Set, but returns ErrCapacity without changing anything when
+// kvs holds more elements than the capacity.
+func (x *Anchor) TrySet(kvs map[string]interface{}) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if len(kvs) > len(x.a) {
+		return ErrCapacity
+	}
+	var gone, keys []string
+	for k := range x.buckets {
+		if _, ok := kvs[k]; !ok {
+			gone = append(gone, k)
+		}
+	}
+	for k := range kvs {
+		keys = append(keys, k)
+	}
+	sort.Strings(gone)
+	sort.Strings(keys)
+	for _, k := range gone {
+		x.remove(k)
+	}
+	for _, k := range keys {
+		x.add(k, kvs[k])
+	}
+	return nil
+}
+
+// Members return all members.
+func (x *Anchor) Members() map[string]interface{} {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	members := make(map[string]interface{}, len(x.buckets))
+	for k, b := range x.buckets {
+		members[k] = x.elems[b]
+	}
+	return members
+}
+
+// Len returns the number of members.
+func (x *Anchor) Len() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return x.n
+}
+
+// Get returns the element of the bucket raw hashes to.
+func (x *Anchor) Get(raw string) (*Element, error) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	if x.n == 0 {
+		return nil, ErrEmptyCircle
+	}
+	return x.elems[x.bucket(x.hasher.Sum64([]byte(raw)))], nil
+}
+
+// GetTwo returns the two closest distinct elements to raw.
+func (x *Anchor) GetTwo(raw string) (*Element, *Element, error) {
+	return getTwo(x.GetN, raw)
+}
+
+// GetN returns the element chosen by Get followed by the elements of the
+// next N-1 working buckets.
+func (x *Anchor) GetN(raw string, n int) ([]*Element, error) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	if x.n == 0 {
+		return nil, ErrEmptyCircle
+	}
+	if x.n < n {
+		n = x.n
+	}
+	if n < 0 {
+		n = 0
+	}
+	pos := x.l[x.bucket(x.hasher.Sum64([]byte(raw)))]
+	res := make([]*Element, n)
+	for i := range res {
+		res[i] = x.elems[x.w[(pos+i)%x.n]]
+	}
+	return res, nil
+}
+
+// bucket returns the working bucket of a key hashing to h.
+// need x.mu.RLock() before calling
+func (x *Anchor) bucket(h uint64) int {
+	b := int(h % uint64(len(x.a)))
+	for x.a[b] > 0 {
+		c := int(murmurFmix64(h^murmurFmix64(uint64(b)+1)) % uint64(x.a[b]))
+		for x.a[c] >= x.a[b] {
+			c = x.k[c]
+		}
+		b = c
+	}
+	return b
+}
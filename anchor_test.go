@@ -0,0 +1,122 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+package consistent
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestNewAnchorCapacity(t *testing.T) {
+	if _, err := NewAnchor(FNV1a, 0); err != ErrInvalidCapacity {
+		t.Errorf("expected invalid capacity error")
+	}
+	x, err := NewAnchor(FNV1a, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkNum(x.Capacity(), 2, t)
+	if _, err := x.Get("abc"); err != ErrEmptyCircle {
+		t.Errorf("expected empty circle error")
+	}
+	if err := x.TryAdd("abcdefg", "value1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := x.TryAdd("hijklmn", "value2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := x.TryAdd("hijklmn", "value2b"); err != nil {
+		t.Errorf("updating an element should not need room: %v", err)
+	}
+	if err := x.TryAdd("opqrstu", "value3"); err != ErrCapacity {
+		t.Errorf("expected capacity error")
+	}
+	x.Add("opqrstu", "value3")
+	checkNum(x.Len(), 2, t)
+	if err := x.TrySet(map[string]interface{}{"a": 1, "b": 2, "c": 3}); err != ErrCapacity {
+		t.Errorf("expected capacity error")
+	}
+	checkNum(x.Len(), 2, t)
+}
+
+func TestAnchorRemoval(t *testing.T) {
+	x, _ := NewAnchor(XXHash, 64)
+	for i := 0; i < 16; i++ {
+		x.Add("member"+strconv.Itoa(i), i)
+	}
+	before := make(map[string]string)
+	counts := make(map[string]int)
+	for i := 0; i < 16000; i++ {
+		e, err := x.Get(strconv.Itoa(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		before[strconv.Itoa(i)] = e.Key
+		counts[e.Key]++
+	}
+	for key, n := range counts {
+		if n < 700 || n > 1300 {
+			t.Errorf("%s: uneven load %d", key, n)
+		}
+	}
+
+	removed := map[string]bool{"member3": true, "member7": true, "member11": true}
+	for _, key := range []string{"member3", "member7", "member11"} {
+		x.Remove(key)
+	}
+	checkNum(x.Len(), 13, t)
+	for k, old := range before {
+		e, _ := x.Get(k)
+		if removed[e.Key] {
+			t.Fatalf("%s still maps to removed %q", k, e.Key)
+		}
+		if !removed[old] && e.Key != old {
+			t.Errorf("%s moved from %q to %q", k, old, e.Key)
+		}
+	}
+
+	// re-adding the last removed element restores its keys
+	x.Add("member11", 11)
+	for k, old := range before {
+		if old != "member11" {
+			continue
+		}
+		if e, _ := x.Get(k); e.Key != "member11" {
+			t.Errorf("%s: expected member11, got %q", k, e.Key)
+		}
+	}
+
+	members, err := x.GetN("abc", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkNum(len(members), 14, t)
+	a, b, _ := x.GetTwo("abc")
+	if members[0] != a || members[1] != b || a == b {
+		t.Errorf("GetTwo and GetN disagree")
+	}
+	seen := make(map[*Element]bool)
+	for _, e := range members {
+		if seen[e] {
+			t.Errorf("duplicate %q", e.Key)
+		}
+		seen[e] = true
+	}
+}
+
+func TestAnchorSet(t *testing.T) {
+	x, _ := NewAnchor(FNV1a, 8)
+	x.Set(map[string]interface{}{"abc": 1, "def": 2, "ghi": 3})
+	b := x.buckets["def"]
+	x.Set(map[string]interface{}{"def": 4, "jkl": 5})
+	checkNum(x.Len(), 2, t)
+	checkNum(x.buckets["def"], b, t)
+	if _, ok := x.Members()["abc"]; ok {
+		t.Errorf("expected abc to be removed")
+	}
+	if x.elems[b].Value != 4 {
+		t.Errorf("expected value to be updated")
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+package consistent
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// DefaultLoadEpsilon is the default ε of bounded-load lookups: no element
+// receives more than 1.25 times the average load.
+const DefaultLoadEpsilon = 0.25
+
+// WithBoundedLoad sets the ε used by GetLeast, so that an element is skipped
+// once its load would exceed (1+ε) times the average load.
+//
+// See "Consistent Hashing with Bounded Loads" by Mirrokni, Thorup and Zadimoghaddam.
+func WithBoundedLoad(epsilon float64) Option {
+	return func(c *Consistent) {
+		c.epsilon = epsilon
+	}
+}
+
+// GetLeast returns the first element clockwise from where raw hashes to in the
+// circle whose load stays within the bound after serving one more request.
+//
+// Callers report load with Inc and Done. GetLeast, Inc and Done do not lock,
+// so load accounting neither contends with lookups nor delays writers.
+func (c *Consistent) GetLeast(raw string) (*Element, error) {
+	v := c.load()
+	if len(v.hashes) == 0 {
+		return nil, ErrEmptyCircle
+	}
+	maxLoad := c.maxLoad(v.count)
+	start := v.search(c.hashKey(raw))
+	i := start
+	for {
+		if v.loads[v.owners[i]].n.Load()+1 <= maxLoad {
+			return v.owner(i), nil
+		}
+		i++
+		if i >= len(v.hashes) {
+			i = 0
+		}
+		if i == start {
+			// unreachable while epsilon >= 0, kept as a safety net
+			return v.owner(start), nil
+		}
+	}
+}
+
+// memberLoad counts the requests an element is serving. Removing the element
+// retires its counter by setting it to -1, after which Inc and Done ignore it.
+type memberLoad struct {
+	n atomic.Int64
+}
+
+// memberLoad returns the load counter of key, creating it for a new member.
+// need c.mu.Lock() before calling
+func (c *Consistent) memberLoad(key string) *memberLoad {
+	if m, ok := c.loads.Load(key); ok {
+		return m.(*memberLoad)
+	}
+	m := new(memberLoad)
+	c.loads.Store(key, m)
+	return m
+}
+
+// dropLoad retires the load counter of key, which left the circle.
+// need c.mu.Lock() before calling
+func (c *Consistent) dropLoad(key string) {
+	m, ok := c.loads.LoadAndDelete(key)
+	if !ok {
+		return
+	}
+	if n := m.(*memberLoad).n.Swap(-1); n > 0 {
+		c.totalLoad.Add(-n)
+	}
+}
+
+// Inc records that element key started serving one more request.
+func (c *Consistent) Inc(key string) {
+	m, ok := c.loads.Load(key)
+	if !ok {
+		return
+	}
+	for n := &m.(*memberLoad).n; ; {
+		old := n.Load()
+		if old < 0 {
+			return
+		}
+		if n.CompareAndSwap(old, old+1) {
+			c.totalLoad.Add(1)
+			return
+		}
+	}
+}
+
+// Done records that element key finished serving a request.
+func (c *Consistent) Done(key string) {
+	m, ok := c.loads.Load(key)
+	if !ok {
+		return
+	}
+	for n := &m.(*memberLoad).n; ; {
+		old := n.Load()
+		if old <= 0 {
+			return
+		}
+		if n.CompareAndSwap(old, old-1) {
+			c.totalLoad.Add(-1)
+			return
+		}
+	}
+}
+
+// Loads returns the current load of every element.
+func (c *Consistent) Loads() map[string]int64 {
+	v := c.load()
+	loads := make(map[string]int64, v.count)
+	for slot, e := range v.elems {
+		if e != nil {
+			n := v.loads[slot].n.Load()
+			if n < 0 {
+				n = 0
+			}
+			loads[e.Key] = n
+		}
+	}
+	return loads
+}
+
+// MaxLoad returns the highest load an element may reach before GetLeast skips it.
+func (c *Consistent) MaxLoad() int64 {
+	return c.maxLoad(c.load().count)
+}
+
+// maxLoad returns the bound of GetLeast for a circle of count elements.
+func (c *Consistent) maxLoad(count int) int64 {
+	if count == 0 {
+		return 0
+	}
+	avg := float64(c.totalLoad.Load()+1) / float64(count)
+	return int64(math.Ceil(avg * (1 + c.epsilon)))
+}
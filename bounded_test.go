@@ -0,0 +1,121 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+package consistent
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestGetLeastEmpty(t *testing.T) {
+	x := New()
+	if _, err := x.GetLeast("abcdefg"); err != ErrEmptyCircle {
+		t.Errorf("expected empty circle error")
+	}
+}
+
+func TestGetLeastNoLoad(t *testing.T) {
+	x := New()
+	x.Add("abcdefg", "value1")
+	x.Add("hijklmn", "value2")
+	x.Add("opqrstu", "value3")
+	for _, v := range gmtests {
+		a, err := x.GetLeast(v.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Key != v.out {
+			t.Errorf("got %q, expected %q", a.Key, v.out)
+		}
+	}
+}
+
+func TestGetLeastBounded(t *testing.T) {
+	x := New(WithBoundedLoad(0.25))
+	for i := 0; i < 4; i++ {
+		x.Add("member"+strconv.Itoa(i), i)
+	}
+	// route every request for the same hot key
+	for i := 0; i < 400; i++ {
+		a, err := x.GetLeast("hot")
+		if err != nil {
+			t.Fatal(err)
+		}
+		x.Inc(a.Key)
+	}
+	max := x.MaxLoad()
+	for key, load := range x.Loads() {
+		if load > max {
+			t.Errorf("%s: load %d exceeds bound %d", key, load, max)
+		}
+		if load == 0 {
+			t.Errorf("%s: expected load to spill over", key)
+		}
+	}
+	for key, load := range x.Loads() {
+		for i := int64(0); i < load; i++ {
+			x.Done(key)
+		}
+	}
+	for key, load := range x.Loads() {
+		checkNum(int(load), 0, t)
+		x.Done(key)
+	}
+	checkNum(int(x.totalLoad.Load()), 0, t)
+}
+
+func TestLoadsFollowMembers(t *testing.T) {
+	x := New()
+	x.Add("abc", "value-abc")
+	x.Add("def", "value-def")
+	x.Inc("abc")
+	x.Inc("abc")
+	x.Inc("def")
+	x.Inc("xyz")
+	checkNum(int(x.totalLoad.Load()), 3, t)
+	x.Set(map[string]interface{}{"abc": "value-abc", "ghi": "value-ghi"})
+	checkNum(int(x.Loads()["abc"]), 2, t)
+	checkNum(int(x.totalLoad.Load()), 2, t)
+	x.Remove("abc")
+	checkNum(int(x.totalLoad.Load()), 0, t)
+	checkNum(len(x.Loads()), 1, t)
+}
+
+func TestLoadsConcurrent(t *testing.T) {
+	x := New()
+	for i := 0; i < 4; i++ {
+		x.Add("member"+strconv.Itoa(i), i)
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				a, err := x.GetLeast(strconv.Itoa(g*1000 + i))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				x.Inc(a.Key)
+				x.Done(a.Key)
+			}
+		}(g)
+	}
+	for i := 0; i < 100; i++ {
+		x.Add("extra", nil)
+		x.Inc("extra")
+		x.Remove("extra")
+	}
+	wg.Wait()
+	checkNum(int(x.totalLoad.Load()), 0, t)
+	for key, load := range x.Loads() {
+		checkNum(int(load), 0, t)
+		if key == "extra" {
+			t.Errorf("expected extra to be removed")
+		}
+	}
+}
@@ -17,15 +17,15 @@
 // get remapped.
 //
 // Read more about consistent hashing on wikipedia:  http://en.wikipedia.org/wiki/Consistent_hashing
-//
 package consistent
 
 import (
+	"encoding/binary"
 	"errors"
-	"hash/crc32"
 	"sort"
-	"strconv"
 	"sync"
+	"sync/atomic"
+	"unsafe"
 )
 
 const (
@@ -33,7 +33,7 @@ const (
 	DefaultReplicaNumber = 20
 )
 
-type uints []uint32
+type uints []uint64
 
 // Len returns the length of the uints array.
 func (x uints) Len() int { return len(x) }
@@ -47,40 +47,169 @@ func (x uints) Swap(i, j int) { x[i], x[j] = x[j], x[i] }
 // ErrEmptyCircle is the error returned when trying to get an element when nothing has been added to hash.
 var ErrEmptyCircle = errors.New("empty circle")
 
-// Element contains key、value and replica
+// Element contains key、value、replica、weight and topology
 type Element struct {
 	Key     string
 	Value   interface{}
 	Replica int
+	// Weight is the weight given to AddWeighted or SetWeighted. Other
+	// elements have weight Replica / NumberOfReplicas.
+	Weight float64
+	// Topology locates the element for GetNDistinct.
+	Topology Topology
+
+	// weighted elements share the point budget of AddWeighted.
+	weighted bool
 }
 
 // Consistent holds the information about the members of the consistent hash circle.
+//
+// Writers serialize on mu and publish an immutable view of the circle after
+// every change; Get, GetTwo, GetN, GetLeast and Members read the latest
+// published view without locking, and Inc and Done update atomic counters.
 type Consistent struct {
-	circle           map[uint32]string
+	view             atomic.Value // *view
+	version          uint64
+	circle           map[uint64]string
+	shadowed         map[uint64][]string
+	collisions       int
 	members          map[string]*Element
+	slots            map[string]int32 // slot of every member in elems
+	elems            []*Element       // members by slot, nil for free slots
+	counters         []*memberLoad    // loads by slot, like elems
+	free             []int32          // free slots of elems
 	sortedHashes     uints
+	dirty            uints // points whose owner changed since the last publish
+	rebuild          bool  // the next publish rebuilds the view from scratch
 	NumberOfReplicas int
 	count            int64
+	loads            sync.Map // key -> *memberLoad, read without mu
+	totalLoad        atomic.Int64
+	epsilon          float64
+	hasher           Hasher
+	hasher64         Hasher64
+	codec            ValueCodec
+	scheme           KeyScheme
+	events           events
+	reweighed        []*Element // elements changed by reweigh, before the change
 	scratch          [64]byte
-	sync.RWMutex
+	mu               sync.RWMutex
+}
+
+// Option configures a Consistent object created by New.
+type Option func(*Consistent)
+
+// WithHasher sets the hash function used to place elements and keys on the circle.
+func WithHasher(h Hasher) Option {
+	return func(c *Consistent) {
+		c.hasher = h
+		c.hasher64 = nil
+	}
+}
+
+// WithHasher64 switches the circle to 64-bit points hashed with h.
+//
+// A 32-bit circle holding thousands of members, each with NumberOfReplicas
+// points, is likely to contain colliding points; the 64-bit circle makes
+// collisions practically impossible at the cost of a different placement.
+func WithHasher64(h Hasher64) Option {
+	return func(c *Consistent) {
+		c.hasher = h
+		c.hasher64 = h
+	}
 }
 
 // New creates a new Consistent object with a default setting of 20 replicas for each entry.
+// Keys are hashed with CRC32 unless another hasher is supplied with WithHasher.
 //
 // To change the number of replicas, set NumberOfReplicas before adding entries.
-func New() *Consistent {
+func New(opts ...Option) *Consistent {
 	c := new(Consistent)
+	c.init()
+	for _, opt := range opts {
+		opt(c)
+	}
+	if _, ok := c.scheme.(ketamaScheme); ok {
+		// ketama points are 32-bit, so keys must be hashed to 32 bits too
+		c.hasher64 = nil
+	}
+	c.view.Store(&view{inclusive: c.scheme.Inclusive()})
+	return c
+}
+
+// init sets the defaults of New.
+func (c *Consistent) init() {
 	c.NumberOfReplicas = DefaultReplicaNumber
-	c.circle = make(map[uint32]string)
+	c.hasher = CRC32
+	c.codec = JSONCodec
+	c.scheme = Stathat
+	c.circle = make(map[uint64]string)
+	c.shadowed = make(map[uint64][]string)
 	c.members = make(map[string]*Element)
-	c.sortedHashes = make(uints, 0, 1024)
-	return c
+	c.slots = make(map[string]int32)
+	c.epsilon = DefaultLoadEpsilon
 }
 
-// eltKey generates a string key for an element with an index.
-func (c *Consistent) eltKey(elt string, idx int) string {
-	// return elt + "|" + strconv.Itoa(idx)
-	return strconv.Itoa(idx) + elt
+// NewWithHasher creates a new Consistent object that hashes with h.
+func NewWithHasher(h Hasher) *Consistent {
+	return New(WithHasher(h))
+}
+
+// New64 creates a new Consistent object with a 64-bit circle hashed with h.
+func New64(h Hasher64) *Consistent {
+	return New(WithHasher64(h))
+}
+
+// Clone returns an independent copy of c with the same options and members.
+// Loads reported with Inc are not copied.
+func (c *Consistent) Clone() *Consistent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	x := &Consistent{
+		version:          c.version,
+		circle:           make(map[uint64]string, len(c.circle)),
+		shadowed:         make(map[uint64][]string, len(c.shadowed)),
+		collisions:       c.collisions,
+		members:          make(map[string]*Element, len(c.members)),
+		slots:            make(map[string]int32, len(c.slots)),
+		elems:            append([]*Element(nil), c.elems...),
+		counters:         make([]*memberLoad, len(c.counters)),
+		free:             append([]int32(nil), c.free...),
+		sortedHashes:     c.sortedHashes,
+		NumberOfReplicas: c.NumberOfReplicas,
+		count:            c.count,
+		epsilon:          c.epsilon,
+		hasher:           c.hasher,
+		hasher64:         c.hasher64,
+		codec:            c.codec,
+		scheme:           c.scheme,
+	}
+	for k, v := range c.circle {
+		x.circle[k] = v
+	}
+	for k, v := range c.shadowed {
+		x.shadowed[k] = append([]string(nil), v...)
+	}
+	for k, v := range c.members {
+		x.members[k] = v
+	}
+	for k, v := range c.slots {
+		x.slots[k] = v
+		x.counters[v] = x.memberLoad(k)
+	}
+	// the view of c points at the loads of c
+	v := *c.load()
+	v.loads = append([]*memberLoad(nil), x.counters...)
+	x.view.Store(&v)
+	return x
+}
+
+// points returns the points of the first replica virtual nodes of key.
+func (c *Consistent) points(key string, replica int) []uint64 {
+	if replica < 0 {
+		replica = 0
+	}
+	return c.scheme.Points(make([]uint64, 0, replica), key, replica, c.hashKey)
 }
 
 // Add inserts a element in the consistent hash.
@@ -90,36 +219,302 @@ func (c *Consistent) Add(key string, value interface{}) {
 
 // AddReplicas inserts a element with replica number in the consistent hash.
 func (c *Consistent) AddReplicas(key string, value interface{}, replica int) {
-	c.Lock()
-	defer c.Unlock()
-	c.add(key, value, replica)
+	defer c.flush()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	old := c.members[key]
+	c.add(key, value, replica, c.replicaWeight(replica), false)
+	c.publish()
+	c.emitChange(key, old)
+}
+
+// AddWeighted inserts a element whose share of the circle is proportional to
+// weight. The elements added with AddWeighted or SetWeighted split a budget of
+// NumberOfReplicas virtual nodes per element in proportion to their weights,
+// so an element of average weight gets as many virtual nodes as one added with
+// Add and the circle does not grow with the weights. Every element keeps at
+// least one virtual node, and weights <= 0 count as 0.
+func (c *Consistent) AddWeighted(key string, value interface{}, weight float64) {
+	defer c.flush()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	old := c.members[key]
+	c.add(key, value, 0, weight, true)
+	c.publish()
+	c.emitChange(key, old)
 }
 
-// need c.Lock() before calling
-func (c *Consistent) add(key string, value interface{}, replica int) {
-	for i := 0; i < replica; i++ {
-		c.circle[c.hashKey(c.eltKey(key, i))] = key
+// weightedMembers returns the members added with a weight, ordered by key.
+// need c.mu.RLock() before calling
+func (c *Consistent) weightedMembers() []*Element {
+	var elems []*Element
+	for _, e := range c.members {
+		if e.weighted {
+			elems = append(elems, e)
+		}
 	}
-	c.members[key] = &Element{key, value, replica}
-	c.updateSortedHashes()
+	sort.Slice(elems, func(i, j int) bool { return elems[i].Key < elems[j].Key })
+	return elems
+}
+
+// reweigh splits the point budget between the weighted members again after
+// one of them was added or removed. Since the first n points of an element do
+// not depend on n, only the points above the old or new count move.
+// need c.mu.Lock() before calling
+func (c *Consistent) reweigh() {
+	elems := c.weightedMembers()
+	weights := make([]float64, len(elems))
+	for i, e := range elems {
+		weights[i] = e.Weight
+	}
+	var added, removed uints
+	for i, n := range apportion(weights, len(elems)*c.NumberOfReplicas) {
+		e := elems[i]
+		if n == e.Replica {
+			continue
+		}
+		if n < e.Replica {
+			for _, h := range c.points(e.Key, e.Replica)[n:] {
+				if c.release(h, e.Key) {
+					removed = append(removed, h)
+				}
+			}
+		} else {
+			for _, h := range c.points(e.Key, n)[e.Replica:] {
+				if c.claim(h, e.Key) {
+					added = append(added, h)
+				}
+			}
+		}
+		elem := *e
+		elem.Replica = n
+		c.setMember(&elem)
+		c.reweighed = append(c.reweighed, e)
+	}
+	c.deleteSortedHashes(removed)
+	c.insertSortedHashes(added)
+}
+
+// apportion splits budget points between weights in proportion to them with
+// the largest remainder method, giving every weight at least one point.
+func apportion(weights []float64, budget int) []int {
+	points := make([]int, len(weights))
+	top := 0.0
+	for _, w := range weights {
+		if w > top {
+			top = w
+		}
+	}
+	// dividing by the largest weight first keeps the total finite
+	total := 0.0
+	for _, w := range weights {
+		if w > 0 {
+			total += w / top
+		}
+	}
+	order := make([]int, 0, len(weights))
+	rests := make([]float64, len(weights))
+	left := budget
+	for i, w := range weights {
+		if w > 0 && total > 0 {
+			quota := w / top / total * float64(budget)
+			points[i] = int(quota)
+			rests[i] = quota - float64(points[i])
+			left -= points[i]
+		}
+		order = append(order, i)
+	}
+	sort.SliceStable(order, func(i, j int) bool { return rests[order[i]] > rests[order[j]] })
+	for _, i := range order {
+		if left <= 0 {
+			break
+		}
+		if rests[i] > 0 {
+			points[i]++
+			left--
+		}
+	}
+	for i := range points {
+		if points[i] < 1 {
+			points[i] = 1
+		}
+	}
+	return points
+}
+
+// replicaWeight converts a virtual node count to a weight.
+func (c *Consistent) replicaWeight(replica int) float64 {
+	if c.NumberOfReplicas <= 0 {
+		return 1
+	}
+	return float64(replica) / float64(c.NumberOfReplicas)
+}
+
+// need c.mu.Lock() before calling
+func (c *Consistent) add(key string, value interface{}, replica int, weight float64, weighted bool) {
+	var topology Topology
+	reweigh := weighted
+	if old, ok := c.members[key]; ok {
+		topology = old.Topology
+		reweigh = reweigh || old.weighted
+		c.remove(key)
+	}
+	if replica < 0 {
+		replica = 0
+	}
+	added := make(uints, 0, replica)
+	for _, h := range c.points(key, replica) {
+		if c.claim(h, key) {
+			added = append(added, h)
+		}
+	}
+	c.setMember(&Element{Key: key, Value: value, Replica: replica, Weight: weight, Topology: topology, weighted: weighted})
+	c.insertSortedHashes(added)
 	c.count++
+	if reweigh {
+		c.reweigh()
+	}
+}
+
+// setMember makes e the member of key e.Key, in the slot of the element it
+// replaces if any.
+// need c.mu.Lock() before calling
+func (c *Consistent) setMember(e *Element) {
+	slot, ok := c.slots[e.Key]
+	if !ok {
+		if n := len(c.free); n > 0 {
+			slot, c.free = c.free[n-1], c.free[:n-1]
+		} else {
+			slot = int32(len(c.elems))
+			c.elems = append(c.elems, nil)
+			c.counters = append(c.counters, nil)
+		}
+		c.slots[e.Key] = slot
+	}
+	c.elems[slot] = e
+	c.counters[slot] = c.memberLoad(e.Key)
+	c.members[e.Key] = e
+}
+
+// deleteMember drops the member of key and frees its slot.
+// need c.mu.Lock() before calling
+func (c *Consistent) deleteMember(key string) {
+	if slot, ok := c.slots[key]; ok {
+		c.elems[slot] = nil
+		c.counters[slot] = nil
+		c.free = append(c.free, slot)
+		delete(c.slots, key)
+	}
+	delete(c.members, key)
+}
+
+// resetMembers drops all the members.
+// need c.mu.Lock() before calling
+func (c *Consistent) resetMembers(n int) {
+	c.members = make(map[string]*Element, n)
+	c.slots = make(map[string]int32, n)
+	c.elems = nil
+	c.counters = nil
+	c.free = nil
+}
+
+// claim registers key as a claimant of point h and reports whether h is a new
+// point on the circle. When several elements hash to the same point, the
+// smallest key owns it regardless of insertion order and the others are kept
+// in shadowed so they can take over on removal.
+// need c.mu.Lock() before calling
+func (c *Consistent) claim(h uint64, key string) bool {
+	c.touch(h)
+	owner, ok := c.circle[h]
+	if !ok {
+		c.circle[h] = key
+		return true
+	}
+	if key < owner {
+		c.circle[h] = key
+		key = owner
+	}
+	c.shadowed[h] = append(c.shadowed[h], key)
+	c.collisions++
+	return false
+}
+
+// touch records that the owner of point h may have changed.
+// need c.mu.Lock() before calling
+func (c *Consistent) touch(h uint64) {
+	if !c.rebuild {
+		c.dirty = append(c.dirty, h)
+	}
+}
+
+// release drops one claim of key on point h, handing the point over to the
+// smallest remaining claimant if key owned it. It reports whether h left the
+// circle.
+// need c.mu.Lock() before calling
+func (c *Consistent) release(h uint64, key string) bool {
+	c.touch(h)
+	owner, ok := c.circle[h]
+	if !ok {
+		return false
+	}
+	claims := c.shadowed[h]
+	i := 0
+	if owner == key {
+		if len(claims) == 0 {
+			delete(c.circle, h)
+			return true
+		}
+		for j := range claims {
+			if claims[j] < claims[i] {
+				i = j
+			}
+		}
+		c.circle[h] = claims[i]
+	} else {
+		for i < len(claims) && claims[i] != key {
+			i++
+		}
+		if i == len(claims) {
+			return false
+		}
+	}
+	claims = append(claims[:i], claims[i+1:]...)
+	if len(claims) == 0 {
+		delete(c.shadowed, h)
+	} else {
+		c.shadowed[h] = claims
+	}
+	c.collisions--
+	return false
 }
 
 // Remove removes an element from the hash.
 func (c *Consistent) Remove(key string) {
-	c.Lock()
-	defer c.Unlock()
-	c.remove(key)
+	defer c.flush()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old, ok := c.members[key]; ok {
+		c.remove(key)
+		c.dropLoad(key)
+		if old.weighted {
+			c.reweigh()
+		}
+		c.publish()
+		c.emitChange(key, old)
+	}
 }
 
-// need c.Lock() before calling
+// need c.mu.Lock() before calling
 func (c *Consistent) remove(key string) {
 	if _, ok := c.members[key]; ok {
-		for i := 0; i < c.members[key].Replica; i++ {
-			delete(c.circle, c.hashKey(c.eltKey(key, i)))
+		removed := make(uints, 0, c.members[key].Replica)
+		for _, h := range c.points(key, c.members[key].Replica) {
+			if c.release(h, key) {
+				removed = append(removed, h)
+			}
 		}
-		delete(c.members, key)
-		c.updateSortedHashes()
+		c.deleteMember(key)
+		c.deleteSortedHashes(removed)
 		c.count--
 	}
 }
@@ -127,134 +522,200 @@ func (c *Consistent) remove(key string) {
 // Set sets all the elements in the hash.  If there are existing elements not
 // present in elts, they will be removed.
 func (c *Consistent) Set(kvs map[string]interface{}) {
-	c.Lock()
-	defer c.Unlock()
+	defer c.flush()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(kvs, nil, false)
+	c.publish()
+	c.emit(RingReset, "", nil, nil)
+}
+
+// SetWeighted is like Set, but gives every element the weight found in
+// weights, as AddWeighted does. Elements missing from weights get weight 1.
+func (c *Consistent) SetWeighted(kvs map[string]interface{}, weights map[string]float64) {
+	defer c.flush()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(kvs, weights, true)
+	c.publish()
+	c.emit(RingReset, "", nil, nil)
+}
+
+// set rebuilds the circle from scratch and sorts it once, which is much
+// cheaper than adding the elements one by one.
+// need c.mu.Lock() before calling
+func (c *Consistent) set(kvs map[string]interface{}, weights map[string]float64, weighted bool) {
 	for key := range c.members {
-		c.remove(key)
+		if _, ok := kvs[key]; !ok {
+			c.dropLoad(key)
+		}
 	}
-
+	c.rebuild = true
+	c.circle = make(map[uint64]string, len(kvs)*c.NumberOfReplicas)
+	c.shadowed = make(map[uint64][]string)
+	c.collisions = 0
+	old := c.members
+	c.resetMembers(len(kvs))
+	elems := make([]*Element, 0, len(kvs))
 	for k, v := range kvs {
-		c.add(k, v, c.NumberOfReplicas)
+		elem := &Element{Key: k, Value: v, Replica: c.NumberOfReplicas, Weight: 1, weighted: weighted}
+		if w, ok := weights[k]; ok {
+			elem.Weight = w
+		}
+		if e, ok := old[k]; ok {
+			elem.Topology = e.Topology
+		}
+		elems = append(elems, elem)
+	}
+	if weighted {
+		sort.Slice(elems, func(i, j int) bool { return elems[i].Key < elems[j].Key })
+		weights := make([]float64, len(elems))
+		for i, e := range elems {
+			weights[i] = e.Weight
+		}
+		for i, n := range apportion(weights, len(elems)*c.NumberOfReplicas) {
+			elems[i].Replica = n
+		}
+	}
+	for _, e := range elems {
+		for _, h := range c.points(e.Key, e.Replica) {
+			c.claim(h, e.Key)
+		}
+		c.setMember(e)
 	}
+	c.count = int64(len(kvs))
+	c.updateSortedHashes()
 }
 
 // Members return all members in consistent hash.
 func (c *Consistent) Members() map[string]interface{} {
-	c.RLock()
-	defer c.RUnlock()
-	members := make(map[string]interface{}, len(c.members))
-	for k, v := range c.members {
-		members[k] = v
-	}
-	return members
+	return c.Snapshot().Members()
 }
 
-// Get returns an element close to where name hashes to in the circle.
+// Len returns the number of members in consistent hash.
+func (c *Consistent) Len() int {
+	return c.load().count
+}
+
+// Collisions returns the number of virtual nodes currently shadowed because
+// their point on the circle is owned by another element (or by another
+// virtual node of the same element).
+func (c *Consistent) Collisions() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.collisions
+}
+
+// Get returns an element close to where name hashes to in the circle. It
+// does not allocate with the hashers of this package; other hashers are
+// given a copy of raw.
 func (c *Consistent) Get(raw string) (*Element, error) {
-	c.RLock()
-	defer c.RUnlock()
-	if len(c.circle) == 0 {
-		return nil, ErrEmptyCircle
-	}
-	key := c.hashKey(raw)
-	i := c.search(key)
-	return c.members[c.circle[c.sortedHashes[i]]], nil
+	return c.load().get(c.hashKey(raw))
 }
 
-func (c *Consistent) search(key uint32) (i int) {
-	f := func(x int) bool {
-		return c.sortedHashes[x] > key
-	}
-	i = sort.Search(len(c.sortedHashes), f)
-	if i >= len(c.sortedHashes) {
-		i = 0
-	}
-	return
+// HashKey returns the point key hashes to on the circle, using the hash
+// function the circle was created with. On 32-bit circles the point fits in
+// the low 32 bits.
+//
+// The point can be passed to GetByHash and GetNByHash of any circle using
+// the same hash function, so a key need only be hashed once.
+func (c *Consistent) HashKey(key string) uint64 {
+	return c.hashKey(key)
 }
 
-// GetTwo returns the two closest distinct elements to the name input in the circle.
-func (c *Consistent) GetTwo(name string) (*Element, *Element, error) {
-	c.RLock()
-	defer c.RUnlock()
-	if len(c.circle) == 0 {
-		return nil, nil, ErrEmptyCircle
-	}
-	key := c.hashKey(name)
-	i := c.search(key)
-	first := c.members[c.circle[c.sortedHashes[i]]]
+// GetByHash returns the element closest to point h, as computed by HashKey.
+func (c *Consistent) GetByHash(h uint64) (*Element, error) {
+	return c.load().get(h)
+}
 
-	if c.count == 1 {
-		return first, nil, nil
-	}
+// GetNByHash returns the N closest distinct elements to point h, as computed
+// by HashKey.
+func (c *Consistent) GetNByHash(h uint64, n int) ([]*Element, error) {
+	return c.load().getN(h, n)
+}
 
-	start := i
-	var second *Element
-	for i = start + 1; i != start; i++ {
-		if i >= len(c.sortedHashes) {
-			i = 0
-		}
-		second = c.members[c.circle[c.sortedHashes[i]]]
-		if second != first {
-			break
-		}
-	}
-	return first, second, nil
+// GetBytes is like Get for a key held in a byte slice. It does not allocate
+// with the hashers of this package, and raw does not escape.
+func (c *Consistent) GetBytes(raw []byte) (*Element, error) {
+	return c.load().get(c.hashRaw(raw))
+}
+
+// GetUint64 is like Get for an integer key, which is hashed in its 8-byte
+// big-endian form. It does not allocate with the hashers of this package.
+func (c *Consistent) GetUint64(raw uint64) (*Element, error) {
+	return c.load().get(c.hashUint64(raw))
+}
+
+// GetTwo returns the two closest distinct elements to the name input in the circle.
+func (c *Consistent) GetTwo(name string) (*Element, *Element, error) {
+	return c.load().getTwo(c.hashKey(name))
 }
 
 // GetN returns the N closest distinct elements to the name input in the circle.
 func (c *Consistent) GetN(name string, n int) ([]*Element, error) {
-	c.RLock()
-	defer c.RUnlock()
+	return c.load().getN(c.hashKey(name), n)
+}
 
-	if len(c.circle) == 0 {
-		return nil, ErrEmptyCircle
-	}
+// GetNInto is like GetN, but appends the elements to dst[:0] and returns the
+// extended slice. It does not allocate when dst has room for n elements and
+// the circle uses one of the hashers of this package.
+func (c *Consistent) GetNInto(dst []*Element, name string, n int) ([]*Element, error) {
+	return c.load().appendN(dst[:0], c.hashKey(name), n)
+}
 
-	if c.count < int64(n) {
-		n = int(c.count)
+func (c *Consistent) search(key uint64) (i int) {
+	inclusive := c.scheme.Inclusive()
+	f := func(x int) bool {
+		return c.sortedHashes[x] > key || inclusive && c.sortedHashes[x] == key
 	}
+	i = sort.Search(len(c.sortedHashes), f)
+	if i >= len(c.sortedHashes) {
+		i = 0
+	}
+	return
+}
 
-	var (
-		key   = c.hashKey(name)
-		i     = c.search(key)
-		start = i
-		res   = make([]*Element, 0, n)
-		elem  = c.members[c.circle[c.sortedHashes[i]]]
-	)
-
-	res = append(res, elem)
+// hashKey returns the point of key on the circle. 32-bit circles keep their
+// points in the low half of the uint64.
+func (c *Consistent) hashKey(key string) uint64 {
+	// the bytes of key are only read, so they need not be copied
+	return c.hashRaw(unsafe.Slice(unsafe.StringData(key), len(key)))
+}
 
-	if len(res) == n {
-		return res, nil
+// hashRaw is hashBytes for keys owned by the caller. The hashers of this
+// package hash key in place; other hashers get a copy, so that key does not
+// escape to the heap.
+func (c *Consistent) hashRaw(key []byte) uint64 {
+	if h, ok := builtinSum(c.hasher, c.hasher64 != nil, key); ok {
+		return h
 	}
+	return c.hashBytes(append([]byte(nil), key...))
+}
 
-	for i = start + 1; i != start; i++ {
-		if i >= len(c.sortedHashes) {
-			i = 0
-		}
-		elem = c.members[c.circle[c.sortedHashes[i]]]
-		if !sliceContainsMember(res, elem) {
-			res = append(res, elem)
-		}
-		if len(res) == n {
-			break
-		}
+// hashBytes returns the point of key on the circle.
+func (c *Consistent) hashBytes(key []byte) uint64 {
+	if c.hasher64 != nil {
+		return c.hasher64.Sum64(key)
 	}
-
-	return res, nil
+	if c.hasher == nil {
+		// zero Consistent, hash like New would
+		return uint64(CRC32.Sum32(key))
+	}
+	return uint64(c.hasher.Sum32(key))
 }
 
-func (c *Consistent) hashKey(key string) uint32 {
-	if len(key) < 64 {
-		var scratch [64]byte
-		copy(scratch[:], key)
-		return crc32.ChecksumIEEE(scratch[:len(key)])
-	}
-	return crc32.ChecksumIEEE([]byte(key))
+// hashUint64 returns the point of the 8-byte big-endian encoding of key.
+func (c *Consistent) hashUint64(key uint64) uint64 {
+	var scratch [8]byte
+	binary.BigEndian.PutUint64(scratch[:], key)
+	return c.hashRaw(scratch[:])
 }
 
+// updateSortedHashes rebuilds sortedHashes from the circle. Like the
+// incremental updates below it builds a new slice, since published views
+// share sortedHashes.
 func (c *Consistent) updateSortedHashes() {
-	hashes := c.sortedHashes[:0]
+	hashes := make(uints, 0, len(c.circle))
 	for k := range c.circle {
 		hashes = append(hashes, k)
 	}
@@ -262,11 +723,42 @@ func (c *Consistent) updateSortedHashes() {
 	c.sortedHashes = hashes
 }
 
-func sliceContainsMember(set []*Element, member *Element) bool {
-	for _, m := range set {
-		if m == member {
-			return true
+// insertSortedHashes merges the new points into a copy of sortedHashes,
+// moving the runs between them with copy.
+func (c *Consistent) insertSortedHashes(added uints) {
+	if len(added) == 0 {
+		return
+	}
+	sort.Sort(added)
+	old := c.sortedHashes
+	hashes := make(uints, len(old)+len(added))
+	i, k := 0, 0
+	for _, h := range added {
+		n := i + sort.Search(len(old)-i, func(x int) bool { return old[i+x] > h })
+		k += copy(hashes[k:], old[i:n])
+		hashes[k] = h
+		i, k = n, k+1
+	}
+	copy(hashes[k:], old[i:])
+	c.sortedHashes = hashes
+}
+
+// deleteSortedHashes splices the removed points out of a copy of sortedHashes.
+func (c *Consistent) deleteSortedHashes(removed uints) {
+	if len(removed) == 0 {
+		return
+	}
+	sort.Sort(removed)
+	old := c.sortedHashes
+	hashes := make(uints, 0, len(old))
+	i := 0
+	for _, h := range removed {
+		n := i + sort.Search(len(old)-i, func(x int) bool { return old[i+x] >= h })
+		hashes = append(hashes, old[i:n]...)
+		i = n
+		if i < len(old) && old[i] == h {
+			i++
 		}
 	}
-	return false
+	c.sortedHashes = append(hashes, old[i:]...)
 }
@@ -6,11 +6,13 @@ package consistent
 
 import (
 	"bufio"
+	"math"
 	"math/rand"
 	"os"
 	"runtime"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"testing/quick"
@@ -82,7 +84,7 @@ func TestGetSingle(t *testing.T) {
 			t.Logf("error: %q", err)
 			return false
 		}
-		t.Logf("s = %q, y = %q", s, y)
+		t.Logf("s = %q, y = %q", s, y.Key)
 		return y.Key == "abcdefg"
 	}
 	if err := quick.Check(f, nil); err != nil {
@@ -112,7 +114,7 @@ func TestGetMultiple(t *testing.T) {
 			t.Fatal(err)
 		}
 		if result.Key != v.out {
-			t.Errorf("%d. got %q, expected %q", i, result, v.out)
+			t.Errorf("%d. got %q, expected %q", i, result.Key, v.out)
 		}
 	}
 }
@@ -128,7 +130,7 @@ func TestGetMultipleQuick(t *testing.T) {
 			t.Logf("error: %q", err)
 			return false
 		}
-		t.Logf("s = %q, y = %q", s, y)
+		t.Logf("s = %q, y = %q", s, y.Key)
 		return y.Key == "abcdefg" || y.Key == "hijklmn" || y.Key == "opqrstu"
 	}
 	if err := quick.Check(f, nil); err != nil {
@@ -159,7 +161,7 @@ func TestGetMultipleRemove(t *testing.T) {
 			t.Fatal(err)
 		}
 		if result.Key != v.out {
-			t.Errorf("%d. got %q, expected %q before rm", i, result, v.out)
+			t.Errorf("%d. got %q, expected %q before rm", i, result.Key, v.out)
 		}
 	}
 	x.Remove("hijklmn")
@@ -169,7 +171,7 @@ func TestGetMultipleRemove(t *testing.T) {
 			t.Fatal(err)
 		}
 		if result.Key != v.out {
-			t.Errorf("%d. got %q, expected %q after rm", i, result, v.out)
+			t.Errorf("%d. got %q, expected %q after rm", i, result.Key, v.out)
 		}
 	}
 }
@@ -186,7 +188,7 @@ func TestGetMultipleRemoveQuick(t *testing.T) {
 			t.Logf("error: %q", err)
 			return false
 		}
-		t.Logf("s = %q, y = %q", s, y)
+		t.Logf("s = %q, y = %q", s, y.Key)
 		return y.Key == "abcdefg" || y.Key == "hijklmn"
 	}
 	if err := quick.Check(f, nil); err != nil {
@@ -382,7 +384,7 @@ func TestGetNQuick(t *testing.T) {
 			}
 			set[member] = true
 			if member.Key != "abcdefg" && member.Key != "hijklmn" && member.Key != "opqrstu" {
-				t.Logf("invalid member: %q", member)
+				t.Logf("invalid member: %q", member.Key)
 				return false
 			}
 		}
@@ -416,7 +418,7 @@ func TestGetNLessQuick(t *testing.T) {
 			}
 			set[member] = true
 			if member.Key != "abcdefg" && member.Key != "hijklmn" && member.Key != "opqrstu" {
-				t.Logf("invalid member: %q", member)
+				t.Logf("invalid member: %q", member.Key)
 				return false
 			}
 		}
@@ -450,7 +452,7 @@ func TestGetNMoreQuick(t *testing.T) {
 			}
 			set[member] = true
 			if member.Key != "abcdefg" && member.Key != "hijklmn" && member.Key != "opqrstu" {
-				t.Logf("invalid member: %q", member)
+				t.Logf("invalid member: %q", member.Key)
 				return false
 			}
 		}
@@ -683,15 +685,13 @@ func TestCollisionsCRC(t *testing.T) {
 		t.Fatal(err)
 	}
 	defer f.Close()
-	found := make(map[uint32]string)
+	found := make(map[uint64]string)
 	scanner := bufio.NewScanner(f)
 	count := 0
 	for scanner.Scan() {
 		word := scanner.Text()
-		for i := 0; i < c.NumberOfReplicas; i++ {
-			ekey := c.eltKey(word, i)
-			// ekey := word + "|" + strconv.Itoa(i)
-			k := c.hashKey(ekey)
+		for i, k := range c.points(word, c.NumberOfReplicas) {
+			ekey := strconv.Itoa(i) + word
 			exist, ok := found[k]
 			if ok {
 				t.Logf("found collision: %s, %s", ekey, exist)
@@ -740,3 +740,480 @@ func TestConcurrentGetSet(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+func TestNewWithHasher(t *testing.T) {
+	for _, h := range []Hasher{CRC32, FNV1a, XXHash, Murmur3, SipHash} {
+		x := NewWithHasher(h)
+		x.Add("abcdefg", "value1")
+		x.Add("hijklmn", "value2")
+		x.Add("opqrstu", "value3")
+		checkNum(len(x.circle), 60, t)
+		for _, s := range []string{"ggg", "hhh", "iiiii"} {
+			a, err := x.Get(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if a.Key != "abcdefg" && a.Key != "hijklmn" && a.Key != "opqrstu" {
+				t.Errorf("invalid element: %q", a.Key)
+			}
+		}
+	}
+	// default hasher must keep the historical placement
+	x := New()
+	if x.hashKey("abcdefg") != uint64(CRC32.Sum32([]byte("abcdefg"))) {
+		t.Errorf("expected crc32 to be the default hasher")
+	}
+}
+
+func TestNew64(t *testing.T) {
+	x := New64(FNV1a)
+	for i := 0; i < 2000; i++ {
+		x.Add("member"+strconv.Itoa(i), i)
+	}
+	checkNum(len(x.circle), 2000*x.NumberOfReplicas, t)
+	checkNum(len(x.sortedHashes), 2000*x.NumberOfReplicas, t)
+	if sort.IsSorted(x.sortedHashes) == false {
+		t.Errorf("expected sorted hashes to be sorted")
+	}
+	if x.hashKey("abcdefg") != FNV1a.Sum64([]byte("abcdefg")) {
+		t.Errorf("expected 64-bit points")
+	}
+	members, err := x.GetN("abcdefg", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkNum(len(members), 3, t)
+	a, b, err := x.GetTwo("abcdefg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != members[0] || b != members[1] {
+		t.Errorf("GetTwo and GetN disagree")
+	}
+	x.Remove(a.Key)
+	c, err := x.Get("abcdefg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != b {
+		t.Errorf("expected %q to take over, got %q", b.Key, c.Key)
+	}
+}
+
+func TestCollisionTieBreak(t *testing.T) {
+	// every element's i-th virtual node lands on the same point
+	h := HasherFunc(func(data []byte) uint32 { return uint32(data[0]) })
+	x := NewWithHasher(h)
+	x.AddReplicas("b", "value-b", 3)
+	x.AddReplicas("a", "value-a", 3)
+	y := NewWithHasher(h)
+	y.AddReplicas("a", "value-a", 3)
+	y.AddReplicas("b", "value-b", 3)
+	for _, r := range []*Consistent{x, y} {
+		checkNum(len(r.circle), 3, t)
+		checkNum(r.Collisions(), 3, t)
+		for p, owner := range r.circle {
+			if owner != "a" {
+				t.Errorf("point %d owned by %q, expected a", p, owner)
+			}
+		}
+	}
+
+	x.Remove("a")
+	checkNum(len(x.circle), 3, t)
+	checkNum(len(x.sortedHashes), 3, t)
+	checkNum(x.Collisions(), 0, t)
+	e, err := x.Get("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Key != "b" {
+		t.Errorf("expected b to take over, got %q", e.Key)
+	}
+
+	y.Remove("b")
+	checkNum(len(y.circle), 3, t)
+	checkNum(y.Collisions(), 0, t)
+	for p, owner := range y.circle {
+		if owner != "a" {
+			t.Errorf("point %d owned by %q after removing b", p, owner)
+		}
+	}
+	y.Remove("a")
+	checkNum(len(y.circle), 0, t)
+	checkNum(len(y.sortedHashes), 0, t)
+}
+
+func TestCollisionSelf(t *testing.T) {
+	h := HasherFunc(func(data []byte) uint32 { return 7 })
+	x := NewWithHasher(h)
+	x.AddReplicas("a", "value-a", 4)
+	checkNum(len(x.circle), 1, t)
+	checkNum(x.Collisions(), 3, t)
+	x.Add("a", "value-a")
+	checkNum(x.Collisions(), x.NumberOfReplicas-1, t)
+	checkNum(int(x.count), 1, t)
+	x.Remove("a")
+	checkNum(len(x.circle), 0, t)
+	checkNum(x.Collisions(), 0, t)
+}
+
+func TestAddWeighted(t *testing.T) {
+	x := New()
+	x.AddWeighted("light", "value-light", 0.5)
+	x.AddWeighted("heavy", "value-heavy", 2)
+	checkNum(x.members["light"].Replica, 8, t)
+	checkNum(x.members["heavy"].Replica, 32, t)
+	if x.members["heavy"].Weight != 2 {
+		t.Errorf("expected weight 2, got %v", x.members["heavy"].Weight)
+	}
+	x.Add("plain", "value-plain")
+	checkNum(x.members["plain"].Replica, x.NumberOfReplicas, t)
+	if x.members["plain"].Weight != 1 {
+		t.Errorf("expected weight 1, got %v", x.members["plain"].Weight)
+	}
+	x.AddReplicas("half", "value-half", 10)
+	if x.members["half"].Weight != 0.5 {
+		t.Errorf("expected weight 0.5, got %v", x.members["half"].Weight)
+	}
+	x.Remove("light")
+	checkNum(x.members["heavy"].Replica, x.NumberOfReplicas, t)
+	checkNum(len(x.circle), len(x.sortedHashes), t)
+}
+
+func TestAddWeightedProportional(t *testing.T) {
+	for _, weights := range [][]float64{
+		{150, 300},
+		{0.01, 0.05},
+		{1, 2, 3, 4},
+		{1e-6, 3e-6},
+		{1e9, 4e9, 2e9},
+	} {
+		x := New()
+		sum, total := 0.0, 0
+		for i, w := range weights {
+			x.AddWeighted(strconv.Itoa(i), i, w)
+			sum += w
+		}
+		for i, w := range weights {
+			want := w / sum * float64(len(weights)*x.NumberOfReplicas)
+			got := x.members[strconv.Itoa(i)].Replica
+			if math.Abs(float64(got)-want) > 1 {
+				t.Errorf("%v: weight %v got %d virtual nodes, want about %.1f", weights, w, got, want)
+			}
+			total += got
+		}
+		checkNum(total, len(weights)*x.NumberOfReplicas, t)
+	}
+}
+
+func TestWeightedDistribution(t *testing.T) {
+	x := New(WithHasher(FNV1a))
+	x.NumberOfReplicas = 200
+	x.SetWeighted(map[string]interface{}{"a": "a", "b": "b", "c": "c"},
+		map[string]float64{"a": 1, "b": 3})
+	checkNum(x.members["a"].Replica, 120, t)
+	checkNum(x.members["b"].Replica, 360, t)
+	checkNum(x.members["c"].Replica, 120, t)
+	hits := make(map[string]int)
+	for i := 0; i < 50000; i++ {
+		e, err := x.Get(strconv.Itoa(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		hits[e.Key]++
+	}
+	ratio := float64(hits["b"]) / float64(hits["a"])
+	if ratio < 2.5 || ratio > 3.5 {
+		t.Errorf("expected b to get about 3x the keys of a, got %.2f (%v)", ratio, hits)
+	}
+}
+
+func TestSortedHashesIncremental(t *testing.T) {
+	h := HasherFunc(func(data []byte) uint32 { return FNV1a.Sum32(data) % 512 })
+	x := NewWithHasher(h)
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		key := "member" + strconv.Itoa(rnd.Intn(40))
+		if rnd.Intn(3) == 0 {
+			x.Remove(key)
+		} else {
+			x.AddReplicas(key, key, 1+rnd.Intn(30))
+		}
+		if i%100 == 0 {
+			x.Set(map[string]interface{}{"a": "a", key: key})
+		}
+		if !sort.IsSorted(x.sortedHashes) {
+			t.Fatalf("%d: expected sorted hashes to be sorted", i)
+		}
+		checkNum(len(x.sortedHashes), len(x.circle), t)
+		for _, p := range x.sortedHashes {
+			if _, ok := x.circle[p]; !ok {
+				t.Fatalf("%d: stale point %d in sorted hashes", i, p)
+			}
+		}
+	}
+}
+
+func BenchmarkSetLarge(b *testing.B) {
+	kvs := make(map[string]interface{}, 5000)
+	for i := 0; i < 5000; i++ {
+		kvs["member"+strconv.Itoa(i)] = i
+	}
+	x := New()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		x.Set(kvs)
+	}
+}
+
+func BenchmarkAddHuge(b *testing.B) {
+	x := New()
+	for i := 0; i < 5000; i++ {
+		x.Add("start"+strconv.Itoa(i), "")
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		x.Add("foo"+strconv.Itoa(i), "")
+		x.Remove("foo" + strconv.Itoa(i))
+	}
+}
+
+func TestPublishedRingIsImmutable(t *testing.T) {
+	x := New()
+	x.Add("abcdefg", "value1")
+	x.Add("hijklmn", "value2")
+	r := x.load()
+	checkNum(int(r.version), 2, t)
+	x.Add("opqrstu", "value3")
+	x.Remove("abcdefg")
+	x.Remove("abcdefg")
+	checkNum(r.count, 2, t)
+	checkNum(len(r.hashes), 40, t)
+	if _, ok := (Snapshot{v: r}).Members()["abcdefg"]; !ok {
+		t.Errorf("expected old view to keep abcdefg")
+	}
+	for i := range r.owners {
+		if e := r.owner(i); e.Key != "abcdefg" && e.Key != "hijklmn" {
+			t.Errorf("%d: unexpected owner %q in old view", i, r.owner(i).Key)
+		}
+	}
+	checkNum(int(x.load().version), 4, t)
+	members, err := x.GetN("abcdefg", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkNum(len(members), 0, t)
+}
+
+func TestAddNegativeReplicas(t *testing.T) {
+	x := New()
+	x.AddReplicas("abcdefg", "value1", -1)
+	checkNum(len(x.sortedHashes), 0, t)
+	checkNum(x.members["abcdefg"].Replica, 0, t)
+	if _, err := x.Get("abcdefg"); err != ErrEmptyCircle {
+		t.Errorf("expected empty circle error, got %v", err)
+	}
+	x.Add("hijklmn", "value2")
+	x.Remove("abcdefg")
+	checkNum(len(x.sortedHashes), x.NumberOfReplicas, t)
+}
+
+func TestPublishIncremental(t *testing.T) {
+	// a tiny hash space makes most points shared by several elements
+	x := New(WithHasher(HasherFunc(func(data []byte) uint32 { return CRC32.Sum32(data) & 0x3ff })))
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		key := "elt" + strconv.Itoa(rnd.Intn(60))
+		switch rnd.Intn(6) {
+		case 0:
+			x.Remove(key)
+		case 1:
+			x.AddReplicas(key, i, 1+rnd.Intn(40))
+		case 2:
+			x.SetTopology(key, Topology{Rack: strconv.Itoa(i)})
+		case 3:
+			if rnd.Intn(50) == 0 {
+				x.Set(map[string]interface{}{key: i})
+			}
+		default:
+			x.Add(key, i)
+		}
+		v := x.load()
+		checkNum(len(v.hashes), len(x.circle), t)
+		checkNum(v.count, len(x.members), t)
+		for j, h := range v.hashes {
+			if want := x.members[x.circle[h]]; v.owner(j) != want {
+				t.Fatalf("step %d: point %d owned by %s, want %s", i, h, v.owner(j).Key, want.Key)
+			}
+		}
+	}
+}
+
+func TestZeroValueGet(t *testing.T) {
+	var x Consistent
+	if _, err := x.Get("abcdefg"); err != ErrEmptyCircle {
+		t.Errorf("expected empty circle error, got %v", err)
+	}
+	checkNum(x.Len(), 0, t)
+	checkNum(len(x.Members()), 0, t)
+}
+
+func BenchmarkGetParallel(b *testing.B) {
+	x := New()
+	for i := 0; i < 10; i++ {
+		x.Add("start"+strconv.Itoa(i), "")
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			x.Get("nothing")
+		}
+	})
+}
+
+func TestGetBytesUint64(t *testing.T) {
+	for _, x := range []*Consistent{New(), NewWithHasher(XXHash), New64(Murmur3), NewWithHasher(HasherFunc(CRC32.Sum32))} {
+		x.Add("abcdefg", "value1")
+		x.Add("hijklmn", "value2")
+		x.Add("opqrstu", "value3")
+		for _, s := range []string{"ggg", "hhh", "iiiii", "a key longer than thirty-two bytes"} {
+			a, err := x.Get(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := x.GetBytes([]byte(s))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if a != b {
+				t.Errorf("Get(%q) and GetBytes disagree", s)
+			}
+		}
+		for i := uint64(0); i < 100; i++ {
+			raw := []byte{0, 0, 0, 0, 0, 0, 0, byte(i)}
+			a, err := x.GetUint64(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b, _ := x.GetBytes(raw); a != b {
+				t.Errorf("GetUint64(%d) and GetBytes disagree", i)
+			}
+		}
+		dst := make([]*Element, 0, 3)
+		members, err := x.GetNInto(dst, "9999999", 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := x.GetN("9999999", 3)
+		checkNum(len(members), 3, t)
+		if &members[0] != &dst[:1][0] {
+			t.Errorf("expected GetNInto to reuse dst")
+		}
+		for i := range want {
+			if members[i] != want[i] {
+				t.Errorf("GetN and GetNInto disagree")
+			}
+		}
+	}
+	if _, err := New().GetUint64(1); err != ErrEmptyCircle {
+		t.Errorf("expected empty circle error")
+	}
+}
+
+// zeroAllocLookups are the lookups that must not allocate.
+func zeroAllocLookups(x *Consistent) map[string]func() {
+	key := []byte("abcdefghijklmn")
+	long := strings.Repeat("abcdefghijklmn", 5)
+	dst := make([]*Element, 0, 3)
+	return map[string]func(){
+		"Get":          func() { x.Get("abcdefghijklmn") },
+		"GetLong":      func() { x.Get(long) },
+		"GetBytes":     func() { x.GetBytes(key) },
+		"GetUint64":    func() { x.GetUint64(1234567) },
+		"GetNInto":     func() { x.GetNInto(dst, "abcdefghijklmn", 3) },
+		"GetNIntoLong": func() { x.GetNInto(dst, long, 3) },
+		"GetBytesStack": func() {
+			var buf [64]byte
+			x.GetBytes(strconv.AppendInt(append(buf[:0], "user:"...), 1234567, 10))
+		},
+	}
+}
+
+func TestZeroAllocLookups(t *testing.T) {
+	for _, x := range []*Consistent{New(), NewWithHasher(FNV1a), NewWithHasher(MD5), New64(XXHash), New64(SipHash)} {
+		for i := 0; i < 10; i++ {
+			x.Add("start"+strconv.Itoa(i), "")
+		}
+		for name, f := range zeroAllocLookups(x) {
+			if n := testing.AllocsPerRun(100, f); n != 0 {
+				t.Errorf("%s: %v allocations per run", name, n)
+			}
+		}
+	}
+}
+
+func benchmarkZeroAlloc(b *testing.B, name string) {
+	x := New()
+	for i := 0; i < 10; i++ {
+		x.Add("start"+strconv.Itoa(i), "")
+	}
+	f := zeroAllocLookups(x)[name]
+	if n := testing.AllocsPerRun(100, f); n != 0 {
+		b.Fatalf("%s: %v allocations per run", name, n)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f()
+	}
+}
+
+func BenchmarkGetBytes(b *testing.B)  { benchmarkZeroAlloc(b, "GetBytes") }
+func BenchmarkGetUint64(b *testing.B) { benchmarkZeroAlloc(b, "GetUint64") }
+func BenchmarkGetNInto(b *testing.B)  { benchmarkZeroAlloc(b, "GetNInto") }
+func BenchmarkGetLong(b *testing.B)   { benchmarkZeroAlloc(b, "GetLong") }
+
+func TestGetByHash(t *testing.T) {
+	x := New()
+	x.Add("abcdefg", "value1")
+	x.Add("hijklmn", "value2")
+	x.Add("opqrstu", "value3")
+	y := New()
+	y.Add("abcdefg", "value1")
+	y.Add("hijklmn", "value2")
+	for i, v := range gmtests {
+		h := x.HashKey(v.in)
+		if h != uint64(CRC32.Sum32([]byte(v.in))) {
+			t.Errorf("%d. unexpected hash %d", i, h)
+		}
+		result, err := x.GetByHash(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Key != v.out {
+			t.Errorf("%d. got %q, expected %q", i, result.Key, v.out)
+		}
+		a, _ := y.Get(v.in)
+		if b, _ := y.GetByHash(h); a != b {
+			t.Errorf("%d. hash is not reusable across circles", i)
+		}
+	}
+	members, err := x.GetNByHash(x.HashKey("9999999"), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := x.GetN("9999999", 3)
+	for i := range want {
+		if members[i] != want[i] {
+			t.Errorf("GetN and GetNByHash disagree")
+		}
+	}
+	s := x.Snapshot()
+	if e, _ := s.GetByHash(x.HashKey("ggg")); e.Key != "abcdefg" {
+		t.Errorf("unexpected snapshot element %q", e.Key)
+	}
+	if _, err := New().GetNByHash(0, 2); err != ErrEmptyCircle {
+		t.Errorf("expected empty circle error")
+	}
+}
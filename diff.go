@@ -0,0 +1,157 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+package consistent
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+// ErrIncompatibleRings is returned by Diff when the two snapshots do not place
+// keys alike: they differ in circle width, hash function or key scheme.
+// Hashers outside this package cannot be told apart and are assumed to match.
+var ErrIncompatibleRings = errors.New("incompatible circles")
+
+// Range is an arc of the circle whose owners differ between two snapshots.
+// Keys hashing to a point p with Start <= p < End belong to the range; when
+// End <= Start the range wraps around zero. With an inclusive KeyScheme the
+// range holds the points p with Start < p <= End instead.
+type Range struct {
+	Start uint64
+	End   uint64
+	// Old and New are the preference lists, as returned by GetN, of the
+	// keys in the range before and after the change.
+	Old []*Element
+	New []*Element
+}
+
+// Movement is the fraction of the keyspace an element gains and loses.
+type Movement struct {
+	Gained float64
+	Lost   float64
+}
+
+// RingDiff describes how keys move between two snapshots.
+type RingDiff struct {
+	// Ranges lists the arcs whose preference lists changed, in circle order.
+	Ranges []Range
+	// Moved is the fraction of the keyspace covered by Ranges.
+	Moved float64
+	// Members holds the keyspace gained and lost by every affected element.
+	Members map[string]Movement
+}
+
+// Diff compares the placement of old and new for preference lists of length
+// n (n = 1 compares Get). Both snapshots must use the same hash function and
+// key scheme.
+func Diff(old, new Snapshot, n int) (RingDiff, error) {
+	diff := RingDiff{Members: make(map[string]Movement)}
+	if !old.compatible(new) {
+		return diff, ErrIncompatibleRings
+	}
+	space := math.Exp2(32)
+	if old.wide() {
+		space = math.Exp2(64)
+	}
+
+	bounds := mergePoints(old.v.hashes, new.v.hashes)
+	for j, start := range bounds {
+		end := bounds[(j+1)%len(bounds)]
+		at := start
+		if old.v.inclusive {
+			at++
+		}
+		before, _ := old.v.getN(at, n)
+		after, _ := new.v.getN(at, n)
+		if sameKeys(before, after) {
+			continue
+		}
+
+		size := arcSize(start, end, space)
+		diff.Moved += size / space
+		for _, e := range before {
+			if !containsKey(after, e.Key) {
+				m := diff.Members[e.Key]
+				m.Lost += size / space
+				diff.Members[e.Key] = m
+			}
+		}
+		for _, e := range after {
+			if !containsKey(before, e.Key) {
+				m := diff.Members[e.Key]
+				m.Gained += size / space
+				diff.Members[e.Key] = m
+			}
+		}
+
+		if last := len(diff.Ranges) - 1; last >= 0 && diff.Ranges[last].End == start &&
+			sameKeys(diff.Ranges[last].Old, before) && sameKeys(diff.Ranges[last].New, after) {
+			diff.Ranges[last].End = end
+			continue
+		}
+		diff.Ranges = append(diff.Ranges, Range{Start: start, End: end, Old: before, New: after})
+	}
+	return diff, nil
+}
+
+// compatible reports whether keys hash to the same points in both snapshots
+// and are placed by the same scheme.
+func (s Snapshot) compatible(t Snapshot) bool {
+	return s.wide() == t.wide() && s.v.inclusive == t.v.inclusive &&
+		hasherName(s.c.hasher) == hasherName(t.c.hasher) &&
+		s.c.scheme.Name() == t.c.scheme.Name()
+}
+
+// wide reports whether the snapshot uses a 64-bit circle.
+func (s Snapshot) wide() bool {
+	return s.c.hasher64 != nil
+}
+
+// arcSize returns the number of points in [start, end) on a circle of the
+// given size; start == end denotes the whole circle.
+func arcSize(start, end uint64, space float64) float64 {
+	if end > start {
+		return float64(end - start)
+	}
+	return space - float64(start-end)
+}
+
+// mergePoints returns the sorted union of two sorted point sets.
+func mergePoints(a, b uints) uints {
+	res := make(uints, 0, len(a)+len(b))
+	res = append(res, a...)
+	res = append(res, b...)
+	sort.Sort(res)
+	j := 0
+	for i := range res {
+		if i == 0 || res[i] != res[j-1] {
+			res[j] = res[i]
+			j++
+		}
+	}
+	return res[:j]
+}
+
+func sameKeys(a, b []*Element) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Key != b[i].Key {
+			return false
+		}
+	}
+	return true
+}
+
+func containsKey(set []*Element, key string) bool {
+	for _, m := range set {
+		if m.Key == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+package consistent
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+// inRange reports whether point p falls into r.
+func inRange(r Range, p uint64) bool {
+	if r.End > r.Start {
+		return p >= r.Start && p < r.End
+	}
+	return p >= r.Start || p < r.End
+}
+
+func TestDiffAdd(t *testing.T) {
+	x := New()
+	x.Add("abcdefg", "value1")
+	x.Add("hijklmn", "value2")
+	x.Add("opqrstu", "value3")
+	before := x.Snapshot()
+	x.Add("vwxyz", "value4")
+	after := x.Snapshot()
+
+	diff, err := Diff(before, after, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Ranges) == 0 {
+		t.Fatal("expected changed ranges")
+	}
+	for _, r := range diff.Ranges {
+		if len(r.Old) != 1 || len(r.New) != 1 || r.New[0].Key != "vwxyz" || r.Old[0].Key == "vwxyz" {
+			t.Errorf("unexpected range %d-%d", r.Start, r.End)
+		}
+	}
+	var lost float64
+	for key, m := range diff.Members {
+		lost += m.Lost
+		if key != "vwxyz" && m.Gained != 0 {
+			t.Errorf("%s should not gain keys", key)
+		}
+	}
+	if math.Abs(diff.Members["vwxyz"].Gained-diff.Moved) > 1e-9 || math.Abs(lost-diff.Moved) > 1e-9 {
+		t.Errorf("gained %v, lost %v, moved %v", diff.Members["vwxyz"].Gained, lost, diff.Moved)
+	}
+
+	moved := 0
+	const keys = 20000
+	for i := 0; i < keys; i++ {
+		k := strconv.Itoa(i)
+		a, _ := before.Get(k)
+		b, _ := after.Get(k)
+		p := x.hashKey(k)
+		found := false
+		for _, r := range diff.Ranges {
+			if inRange(r, p) {
+				found = true
+				break
+			}
+		}
+		if a.Key != b.Key {
+			moved++
+		}
+		if found != (a.Key != b.Key) {
+			t.Fatalf("key %q: moved %v but in range %v", k, a.Key != b.Key, found)
+		}
+	}
+	if got := float64(moved) / keys; math.Abs(got-diff.Moved) > 0.02 {
+		t.Errorf("sampled %.3f of keys moved, diff reports %.3f", got, diff.Moved)
+	}
+}
+
+func TestDiffN(t *testing.T) {
+	x := New()
+	x.Add("abcdefg", "value1")
+	x.Add("hijklmn", "value2")
+	x.Add("opqrstu", "value3")
+	before := x.Snapshot()
+	x.Remove("hijklmn")
+	after := x.Snapshot()
+
+	diff, err := Diff(before, after, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// only the preference lists holding hijklmn change
+	if diff.Members["hijklmn"].Lost != diff.Moved {
+		t.Errorf("expected hijklmn to lose everything that moved")
+	}
+	for _, r := range diff.Ranges {
+		if !containsKey(r.Old, "hijklmn") || containsKey(r.New, "hijklmn") {
+			t.Errorf("unexpected range %d-%d", r.Start, r.End)
+		}
+	}
+}
+
+func TestDiffEmpty(t *testing.T) {
+	x := New()
+	empty := x.Snapshot()
+	diff, err := Diff(empty, empty, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkNum(len(diff.Ranges), 0, t)
+
+	x.Add("abcdefg", "value1")
+	diff, err = Diff(empty, x.Snapshot(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(diff.Moved-1) > 1e-9 || math.Abs(diff.Members["abcdefg"].Gained-1) > 1e-9 {
+		t.Errorf("expected the whole keyspace to move, got %v", diff.Moved)
+	}
+
+	for _, other := range []*Consistent{
+		New64(FNV1a),
+		New(WithHasher(FNV1a)),
+		New(WithKeyScheme(Groupcache)),
+		New(WithKeyScheme(Ketama)),
+	} {
+		if _, err := Diff(empty, other.Snapshot(), 1); err != ErrIncompatibleRings {
+			t.Errorf("expected incompatible circles error")
+		}
+	}
+}
@@ -0,0 +1,408 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+package consistent
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"sort"
+)
+
+var (
+	// ErrInvalidEncoding is returned when decoding malformed or unsupported data.
+	ErrInvalidEncoding = errors.New("invalid encoding")
+	// ErrHasherMismatch is returned when decoding a circle built with another
+	// hash function or key scheme than the receiver's.
+	ErrHasherMismatch = errors.New("hasher mismatch")
+)
+
+// encodingVersion is the version of the JSON and binary encodings.
+const encodingVersion = 1
+
+// maxDecodedPoints bounds the replica counts accepted by the decoders, per
+// element and in total, so that malformed input cannot exhaust memory.
+const maxDecodedPoints = 1 << 24
+
+// binaryMagic prefixes the binary encoding.
+var binaryMagic = []byte("GCH")
+
+// ValueCodec encodes and decodes Element values when serializing a
+// Consistent object. Codecs used with MarshalJSON must produce JSON.
+type ValueCodec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte) (interface{}, error)
+}
+
+// JSONCodec encodes values with encoding/json. It is the default codec;
+// decoded values have the types encoding/json gives to interface{} values,
+// so numbers come back as float64.
+var JSONCodec ValueCodec = jsonCodec{}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec) Unmarshal(data []byte) (interface{}, error) {
+	var v interface{}
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+// WithValueCodec sets the codec used to serialize Element values.
+func WithValueCodec(vc ValueCodec) Option {
+	return func(c *Consistent) {
+		c.codec = vc
+	}
+}
+
+type jsonElement struct {
+	Key      string          `json:"key"`
+	Value    json.RawMessage `json:"value"`
+	Replica  int             `json:"replica"`
+	Weight   float64         `json:"weight"`
+	Weighted bool            `json:"weighted,omitempty"`
+	Topology *Topology       `json:"topology,omitempty"`
+}
+
+type jsonCircle struct {
+	Version  int           `json:"version"`
+	Hasher   string        `json:"hasher"`
+	Scheme   string        `json:"scheme"`
+	Bits     int           `json:"bits"`
+	Replicas int           `json:"replicas"`
+	Members  []jsonElement `json:"members"`
+}
+
+// MarshalJSON encodes the members of the circle, with their replica counts,
+// weights, topologies and values, along with the circle settings.
+func (c *Consistent) MarshalJSON() ([]byte, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	jc := jsonCircle{
+		Version:  encodingVersion,
+		Hasher:   hasherName(c.hasher),
+		Scheme:   c.scheme.Name(),
+		Bits:     c.bits(),
+		Replicas: c.NumberOfReplicas,
+		Members:  make([]jsonElement, 0, len(c.members)),
+	}
+	for _, e := range c.sortedMembers() {
+		value, err := c.codec.Marshal(e.Value)
+		if err != nil {
+			return nil, err
+		}
+		je := jsonElement{Key: e.Key, Value: value, Replica: e.Replica, Weight: e.Weight, Weighted: e.weighted}
+		if e.Topology != (Topology{}) {
+			topo := e.Topology
+			je.Topology = &topo
+		}
+		jc.Members = append(jc.Members, je)
+	}
+	return json.Marshal(jc)
+}
+
+// UnmarshalJSON replaces the members of the circle with the decoded ones.
+// The receiver must use the hash function the data was encoded with; a zero
+// Consistent adopts it if it is one of the hashers of this package.
+func (c *Consistent) UnmarshalJSON(data []byte) error {
+	var jc jsonCircle
+	if err := json.Unmarshal(data, &jc); err != nil {
+		return err
+	}
+	if jc.Version != encodingVersion {
+		return ErrInvalidEncoding
+	}
+	elems := make([]*Element, len(jc.Members))
+	for i, je := range jc.Members {
+		elems[i] = &Element{Key: je.Key, Replica: je.Replica, Weight: je.Weight, weighted: je.Weighted}
+		if je.Topology != nil {
+			elems[i].Topology = *je.Topology
+		}
+	}
+	if jc.Replicas < 0 {
+		return ErrInvalidEncoding
+	}
+	if err := checkDecoded(uint64(jc.Replicas), elems); err != nil {
+		return err
+	}
+
+	defer c.flush()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, je := range jc.Members {
+		value, err := c.valueCodec().Unmarshal(je.Value)
+		if err != nil {
+			return err
+		}
+		elems[i].Value = value
+	}
+	if err := c.prepareDecode(jc.Hasher, jc.Scheme, jc.Bits); err != nil {
+		return err
+	}
+	c.NumberOfReplicas = jc.Replicas
+	c.restore(elems)
+	return nil
+}
+
+// MarshalBinary encodes the circle in a compact versioned binary form
+// holding the same information as MarshalJSON.
+func (c *Consistent) MarshalBinary() ([]byte, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var buf bytes.Buffer
+	buf.Write(binaryMagic)
+	buf.WriteByte(encodingVersion)
+	buf.WriteByte(byte(c.bits()))
+	writeString(&buf, hasherName(c.hasher))
+	writeString(&buf, c.scheme.Name())
+	writeUvarint(&buf, uint64(c.NumberOfReplicas))
+	writeUvarint(&buf, uint64(len(c.members)))
+	for _, e := range c.sortedMembers() {
+		value, err := c.codec.Marshal(e.Value)
+		if err != nil {
+			return nil, err
+		}
+		writeString(&buf, e.Key)
+		writeString(&buf, string(value))
+		writeUvarint(&buf, uint64(e.Replica))
+		writeUvarint(&buf, math.Float64bits(e.Weight))
+		writeBool(&buf, e.weighted)
+		writeString(&buf, e.Topology.Region)
+		writeString(&buf, e.Topology.Zone)
+		writeString(&buf, e.Topology.Rack)
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary replaces the members of the circle with the ones encoded
+// by MarshalBinary, with the same hasher rules as UnmarshalJSON.
+func (c *Consistent) UnmarshalBinary(data []byte) error {
+	if len(data) < len(binaryMagic)+2 || !bytes.Equal(data[:len(binaryMagic)], binaryMagic) ||
+		data[len(binaryMagic)] != encodingVersion {
+		return ErrInvalidEncoding
+	}
+	r := &binaryReader{data: data[len(binaryMagic)+2:]}
+	bits := int(data[len(binaryMagic)+1])
+	name := r.string()
+	scheme := r.string()
+	replicas := r.uvarint()
+	n := r.uvarint()
+	if r.err != nil || n > uint64(len(r.data)) {
+		return ErrInvalidEncoding
+	}
+	type rawElement struct {
+		elem  *Element
+		value []byte
+	}
+	raw := make([]rawElement, n)
+	for i := range raw {
+		e := &Element{Key: r.string()}
+		raw[i].value = []byte(r.string())
+		replica := r.uvarint()
+		if replica > maxDecodedPoints {
+			return ErrInvalidEncoding
+		}
+		e.Replica = int(replica)
+		e.Weight = math.Float64frombits(r.uvarint())
+		e.weighted = r.bool()
+		e.Topology = Topology{Region: r.string(), Zone: r.string(), Rack: r.string()}
+		raw[i].elem = e
+	}
+	if r.err != nil || len(r.data) != 0 {
+		return ErrInvalidEncoding
+	}
+	elems := make([]*Element, len(raw))
+	for i, re := range raw {
+		elems[i] = re.elem
+	}
+	if err := checkDecoded(replicas, elems); err != nil {
+		return err
+	}
+
+	defer c.flush()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, re := range raw {
+		value, err := c.valueCodec().Unmarshal(re.value)
+		if err != nil {
+			return err
+		}
+		re.elem.Value = value
+	}
+	if err := c.prepareDecode(name, scheme, bits); err != nil {
+		return err
+	}
+	c.NumberOfReplicas = int(replicas)
+	c.restore(elems)
+	return nil
+}
+
+// checkDecoded validates decoded elements and replica settings before they
+// are restored.
+func checkDecoded(replicas uint64, elems []*Element) error {
+	if replicas > maxDecodedPoints {
+		return ErrInvalidEncoding
+	}
+	keys := make(map[string]bool, len(elems))
+	total := 0
+	for _, e := range elems {
+		if e.Replica < 0 || e.Replica > maxDecodedPoints || keys[e.Key] {
+			return ErrInvalidEncoding
+		}
+		keys[e.Key] = true
+		total += e.Replica
+		if total > maxDecodedPoints {
+			return ErrInvalidEncoding
+		}
+	}
+	return nil
+}
+
+// bits returns the width of the points of the circle.
+func (c *Consistent) bits() int {
+	if c.hasher64 != nil {
+		return 64
+	}
+	return 32
+}
+
+// sortedMembers returns the members ordered by key.
+// need c.mu.RLock() before calling
+func (c *Consistent) sortedMembers() []*Element {
+	elems := make([]*Element, 0, len(c.members))
+	for _, e := range c.members {
+		elems = append(elems, e)
+	}
+	sort.Slice(elems, func(i, j int) bool { return elems[i].Key < elems[j].Key })
+	return elems
+}
+
+// valueCodec returns the codec of c, or the default one for a zero receiver.
+// need c.mu.RLock() before calling
+func (c *Consistent) valueCodec() ValueCodec {
+	if c.codec == nil {
+		return JSONCodec
+	}
+	return c.codec
+}
+
+// prepareDecode initializes a zero receiver and checks that it places keys
+// like the encoded circle. A zero receiver is left untouched on error.
+// need c.mu.Lock() before calling
+func (c *Consistent) prepareDecode(name, scheme string, bits int) error {
+	if bits != 32 && bits != 64 {
+		return ErrInvalidEncoding
+	}
+	if _, ketama := schemeByName(scheme).(ketamaScheme); ketama && bits == 64 {
+		// New never builds 64-bit ketama circles
+		return ErrInvalidEncoding
+	}
+	if c.circle == nil {
+		h, s := hasherByName(name), schemeByName(scheme)
+		h64, ok := h.(Hasher64)
+		if h == nil || s == nil || bits == 64 && !ok {
+			return ErrHasherMismatch
+		}
+		c.init()
+		c.hasher, c.scheme = h, s
+		if bits == 64 {
+			c.hasher64 = h64
+		}
+		c.view.Store(&view{inclusive: c.scheme.Inclusive()})
+		return nil
+	}
+	if bits != c.bits() || name != hasherName(c.hasher) || scheme != c.scheme.Name() {
+		return ErrHasherMismatch
+	}
+	return nil
+}
+
+// restore rebuilds the circle from elems, keeping their replica counts.
+// need c.mu.Lock() before calling
+func (c *Consistent) restore(elems []*Element) {
+	for key := range c.members {
+		c.dropLoad(key)
+	}
+	c.rebuild = true
+	c.circle = make(map[uint64]string)
+	c.shadowed = make(map[uint64][]string)
+	c.collisions = 0
+	c.resetMembers(len(elems))
+	for _, e := range elems {
+		for _, h := range c.points(e.Key, e.Replica) {
+			c.claim(h, e.Key)
+		}
+		c.setMember(e)
+	}
+	c.count = int64(len(c.members))
+	c.updateSortedHashes()
+	c.publish()
+	c.emit(RingReset, "", nil, nil)
+}
+
+func writeUvarint(buf *bytes.Buffer, v uint64) {
+	var scratch [binary.MaxVarintLen64]byte
+	buf.Write(scratch[:binary.PutUvarint(scratch[:], v)])
+}
+
+func writeBool(buf *bytes.Buffer, b bool) {
+	if b {
+		writeUvarint(buf, 1)
+	} else {
+		writeUvarint(buf, 0)
+	}
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	writeUvarint(buf, uint64(len(s)))
+	buf.WriteString(s)
+}
+
+// binaryReader decodes the binary encoding, remembering the first error.
+type binaryReader struct {
+	data []byte
+	err  error
+}
+
+func (r *binaryReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.err = ErrInvalidEncoding
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *binaryReader) bool() bool {
+	switch r.uvarint() {
+	case 0:
+		return false
+	case 1:
+		return true
+	}
+	r.err = ErrInvalidEncoding
+	return false
+}
+
+func (r *binaryReader) string() string {
+	n := r.uvarint()
+	if r.err != nil {
+		return ""
+	}
+	if n > uint64(len(r.data)) {
+		r.err = ErrInvalidEncoding
+		return ""
+	}
+	s := string(r.data[:n])
+	r.data = r.data[n:]
+	return s
+}
@@ -0,0 +1,244 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+package consistent
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"testing"
+)
+
+func encodingCircle(opts ...Option) *Consistent {
+	x := New(opts...)
+	x.Add("abcdefg", "value1")
+	x.AddReplicas("hijklmn", map[string]interface{}{"port": "11211"}, 7)
+	x.AddWeighted("opqrstu", []interface{}{"a", "b"}, 2.5)
+	x.AddWithTopology("vwxyz", nil, Topology{Region: "eu", Zone: "eu-1"})
+	return x
+}
+
+func checkSameRing(x, y *Consistent, t *testing.T) {
+	checkNum(y.Len(), x.Len(), t)
+	for key, e := range x.members {
+		f, ok := y.members[key]
+		if !ok {
+			t.Errorf("missing member %q", key)
+			continue
+		}
+		if f.Replica != e.Replica || f.Weight != e.Weight || f.weighted != e.weighted || f.Topology != e.Topology {
+			t.Errorf("member %q decoded as %+v, want %+v", key, *f, *e)
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		name := "key" + strconv.Itoa(i)
+		e, _ := x.GetN(name, 3)
+		f, _ := y.GetN(name, 3)
+		for j := range e {
+			if e[j].Key != f[j].Key {
+				t.Fatalf("GetN(%q) = %s, want %s", name, f[j].Key, e[j].Key)
+			}
+		}
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	x := encodingCircle()
+	data, err := json.Marshal(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	y := New()
+	if err := json.Unmarshal(data, y); err != nil {
+		t.Fatal(err)
+	}
+	checkSameRing(x, y, t)
+	if y.members["abcdefg"].Value != "value1" {
+		t.Errorf("unexpected value %v", y.members["abcdefg"].Value)
+	}
+	port := y.members["hijklmn"].Value.(map[string]interface{})["port"]
+	if port != "11211" {
+		t.Errorf("unexpected value %v", port)
+	}
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	x := encodingCircle(WithHasher64(XXHash))
+	data, err := x.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var y Consistent
+	if err := y.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	checkSameRing(x, &y, t)
+	if y.members["opqrstu"].Value.([]interface{})[1] != "b" {
+		t.Errorf("unexpected value %v", y.members["opqrstu"].Value)
+	}
+	again, err := y.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != string(data) {
+		t.Errorf("expected identical re-encoding")
+	}
+}
+
+func TestUnmarshalZeroValue(t *testing.T) {
+	x := encodingCircle()
+	data, err := json.Marshal(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	y := new(Consistent)
+	if err := json.Unmarshal(data, y); err != nil {
+		t.Fatal(err)
+	}
+	checkSameRing(x, y, t)
+	y.Add("newone", nil)
+	checkNum(y.Len(), x.Len()+1, t)
+}
+
+func TestUnmarshalZeroValueAfterError(t *testing.T) {
+	data, err := json.Marshal(encodingCircle(WithHasher(FNV1a)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	y := new(Consistent)
+	for _, bad := range []string{
+		`{"version":1,"hasher":"unknown","scheme":"stathat","bits":32,"replicas":20,"members":[]}`,
+		`{"version":1,"hasher":"crc32","scheme":"unknown","bits":32,"replicas":20,"members":[]}`,
+		`{"version":1,"hasher":"xxhash","scheme":"stathat","bits":64,"replicas":-1,"members":[]}`,
+	} {
+		if err := json.Unmarshal([]byte(bad), y); err == nil {
+			t.Errorf("%s: expected an error", bad)
+		}
+	}
+	if err := json.Unmarshal(data, y); err != nil {
+		t.Fatal(err)
+	}
+	checkNum(y.Len(), 4, t)
+	if hasherName(y.hasher) != "fnv1a" {
+		t.Errorf("expected the fnv1a hasher, got %q", hasherName(y.hasher))
+	}
+}
+
+func TestUnmarshalHasherMismatch(t *testing.T) {
+	data, err := encodingCircle().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := New64(FNV1a).UnmarshalBinary(data); err != ErrHasherMismatch {
+		t.Errorf("expected hasher mismatch, got %v", err)
+	}
+	custom := encodingCircle(WithHasher(HasherFunc(func(b []byte) uint32 { return uint32(len(b)) })))
+	if data, err = custom.MarshalBinary(); err != nil {
+		t.Fatal(err)
+	}
+	if err := new(Consistent).UnmarshalBinary(data); err != ErrHasherMismatch {
+		t.Errorf("expected hasher mismatch, got %v", err)
+	}
+}
+
+func TestUnmarshalBinaryInvalid(t *testing.T) {
+	data, err := encodingCircle().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, bad := range [][]byte{nil, []byte("GCH"), data[:len(data)-1], append(data, 0)} {
+		if err := New().UnmarshalBinary(bad); err != ErrInvalidEncoding {
+			t.Errorf("expected invalid encoding, got %v", err)
+		}
+	}
+}
+
+func TestUnmarshalJSONInvalid(t *testing.T) {
+	for _, bad := range []string{
+		`{"version":1,"hasher":"crc32","scheme":"stathat","bits":32,"replicas":20,"members":[{"key":"a","value":null,"replica":-1}]}`,
+		`{"version":1,"hasher":"crc32","scheme":"stathat","bits":32,"replicas":20,"members":[{"key":"a","value":null,"replica":1000000000}]}`,
+		`{"version":1,"hasher":"crc32","scheme":"stathat","bits":32,"replicas":-5,"members":[]}`,
+		`{"version":1,"hasher":"crc32","scheme":"stathat","bits":32,"replicas":20,"members":[{"key":"a","value":null,"replica":1},{"key":"a","value":null,"replica":2}]}`,
+		`{"version":2,"hasher":"crc32","scheme":"stathat","bits":32,"replicas":20,"members":[]}`,
+		`{"version":1,"hasher":"fnv1a","scheme":"ketama","bits":64,"replicas":20,"members":[]}`,
+	} {
+		x := encodingCircle()
+		if err := json.Unmarshal([]byte(bad), x); err != ErrInvalidEncoding {
+			t.Errorf("%s: expected invalid encoding, got %v", bad, err)
+		}
+		checkNum(x.Len(), 4, t)
+	}
+}
+
+func TestUnmarshalBinaryReplicas(t *testing.T) {
+	encode := func(replicas uint64, members ...uint64) []byte {
+		var buf bytes.Buffer
+		buf.Write(binaryMagic)
+		buf.WriteByte(encodingVersion)
+		buf.WriteByte(32)
+		writeString(&buf, "crc32")
+		writeString(&buf, "stathat")
+		writeUvarint(&buf, replicas)
+		writeUvarint(&buf, uint64(len(members)))
+		for i, replica := range members {
+			writeString(&buf, strconv.Itoa(i))
+			writeString(&buf, "null")
+			writeUvarint(&buf, replica)
+			writeUvarint(&buf, 0)
+			writeBool(&buf, false)
+			writeString(&buf, "")
+			writeString(&buf, "")
+			writeString(&buf, "")
+		}
+		return buf.Bytes()
+	}
+	if err := New().UnmarshalBinary(encode(20, 3, 5)); err != nil {
+		t.Fatal(err)
+	}
+	for _, bad := range [][]byte{
+		encode(20, math.MaxUint64),
+		encode(20, 1<<62),
+		encode(20, maxDecodedPoints, 1),
+		encode(math.MaxUint64, 1),
+	} {
+		if err := New().UnmarshalBinary(bad); err != ErrInvalidEncoding {
+			t.Errorf("expected invalid encoding, got %v", err)
+		}
+	}
+}
+
+type stringCodec struct{}
+
+func (stringCodec) Marshal(v interface{}) ([]byte, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, errors.New("not a string")
+	}
+	return []byte(s), nil
+}
+
+func (stringCodec) Unmarshal(data []byte) (interface{}, error) { return string(data), nil }
+
+func TestValueCodec(t *testing.T) {
+	x := New(WithValueCodec(stringCodec{}))
+	x.Add("abcdefg", "10.0.0.1:11211")
+	data, err := x.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	y := New(WithValueCodec(stringCodec{}))
+	if err := y.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if v := y.members["abcdefg"].Value; v != "10.0.0.1:11211" {
+		t.Errorf("unexpected value %v", v)
+	}
+	x.Add("hijklmn", 42)
+	if _, err := x.MarshalBinary(); err == nil {
+		t.Errorf("expected codec error")
+	}
+}
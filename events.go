@@ -0,0 +1,177 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+package consistent
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// EventType is the kind of change an Event reports.
+type EventType int
+
+const (
+	// MemberAdded reports an element that joined the circle.
+	MemberAdded EventType = iota + 1
+	// MemberRemoved reports an element that left the circle.
+	MemberRemoved
+	// MemberUpdated reports an element that was added again or relabelled,
+	// or a weighted element whose number of virtual nodes changed because
+	// another weighted element was added or removed.
+	MemberUpdated
+	// RingReset reports that the members were replaced at once, by Set,
+	// SetWeighted or decoding. Subscribers should reload Members.
+	RingReset
+)
+
+// String returns the name of t.
+func (t EventType) String() string {
+	switch t {
+	case MemberAdded:
+		return "MemberAdded"
+	case MemberRemoved:
+		return "MemberRemoved"
+	case MemberUpdated:
+		return "MemberUpdated"
+	case RingReset:
+		return "RingReset"
+	}
+	return "EventType(?)"
+}
+
+// Event is a change of the members of a Consistent object.
+type Event struct {
+	Type EventType
+	// Key is the key of the element that changed; it is empty for RingReset.
+	Key string
+	// Element is the element after the change; it is nil for MemberRemoved
+	// and RingReset.
+	Element *Element
+	// Old is the element before the change; it is nil for MemberAdded and
+	// RingReset.
+	Old *Element
+	// Version is the version of the circle after the change, as returned
+	// by Epoch and Snapshot.Version.
+	Version uint64
+}
+
+// subscriber is a registered event callback; its identity is its pointer.
+type subscriber struct {
+	fn       func(Event)
+	canceled atomic.Bool
+}
+
+// pendingEvent is an event waiting for delivery to the subscribers of the
+// time it happened.
+type pendingEvent struct {
+	Event
+	subscribers []*subscriber
+}
+
+// events holds the subscribers of a Consistent object and the events waiting
+// for delivery.
+type events struct {
+	subscribers []*subscriber  // copied on write, guarded by Consistent.mu
+	pending     []pendingEvent // guarded by Consistent.mu
+	queued      atomic.Bool    // pending may be non-empty
+	delivering  sync.Mutex     // held by the goroutine delivering events
+}
+
+// Subscribe registers fn to be called with every later change of the
+// members, and returns a function that cancels the subscription.
+//
+// Events are delivered in version order, one at a time, on the goroutine
+// of one of the writers, after it released the write lock: fn may read and
+// modify the Consistent object, and the events of its own changes are
+// delivered once it returns. A slow fn delays the writer delivering events.
+//
+// fn is not called after cancel returns, except for a call already running
+// on another goroutine.
+func (c *Consistent) Subscribe(fn func(Event)) (cancel func()) {
+	s := &subscriber{fn: fn}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	subscribers := make([]*subscriber, len(c.events.subscribers), len(c.events.subscribers)+1)
+	copy(subscribers, c.events.subscribers)
+	c.events.subscribers = append(subscribers, s)
+	return func() {
+		s.canceled.Store(true)
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		subscribers := make([]*subscriber, 0, len(c.events.subscribers))
+		for _, x := range c.events.subscribers {
+			if x != s {
+				subscribers = append(subscribers, x)
+			}
+		}
+		c.events.subscribers = subscribers
+	}
+}
+
+// SubscribeChan is like Subscribe, but sends the events to ch. Delivery
+// blocks until ch accepts the event, so ch must be drained or buffered.
+func (c *Consistent) SubscribeChan(ch chan<- Event) (cancel func()) {
+	return c.Subscribe(func(e Event) { ch <- e })
+}
+
+// emit queues an event of the current version for delivery by flush.
+// need c.mu.Lock() before calling
+func (c *Consistent) emit(typ EventType, key string, old, elem *Element) {
+	if len(c.events.subscribers) == 0 {
+		return
+	}
+	c.events.pending = append(c.events.pending, pendingEvent{
+		Event:       Event{Type: typ, Key: key, Element: elem, Old: old, Version: c.version},
+		subscribers: c.events.subscribers,
+	})
+	c.events.queued.Store(true)
+}
+
+// emitChange queues the event of key going from old to its current element,
+// followed by the updates of the weighted elements reweigh changed.
+// need c.mu.Lock() before calling
+func (c *Consistent) emitChange(key string, old *Element) {
+	elem := c.members[key]
+	switch {
+	case old == nil && elem != nil:
+		c.emit(MemberAdded, key, nil, elem)
+	case old != nil && elem == nil:
+		c.emit(MemberRemoved, key, old, nil)
+	case old != nil:
+		c.emit(MemberUpdated, key, old, elem)
+	}
+	for _, e := range c.reweighed {
+		if e.Key != key {
+			c.emit(MemberUpdated, e.Key, e, c.members[e.Key])
+		}
+	}
+	c.reweighed = nil
+}
+
+// flush delivers the pending events. It must be called without holding
+// c.mu. When another goroutine is delivering, it picks up the events of
+// this one, so that events are never delivered concurrently or out of order.
+func (c *Consistent) flush() {
+	for c.events.queued.Load() && c.events.delivering.TryLock() {
+		for {
+			c.mu.Lock()
+			pending := c.events.pending
+			c.events.pending = nil
+			c.events.queued.Store(false)
+			c.mu.Unlock()
+			if len(pending) == 0 {
+				break
+			}
+			for _, e := range pending {
+				for _, s := range e.subscribers {
+					if !s.canceled.Load() {
+						s.fn(e.Event)
+					}
+				}
+			}
+		}
+		c.events.delivering.Unlock()
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+package consistent
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestSubscribe(t *testing.T) {
+	x := New()
+	var got []Event
+	cancel := x.Subscribe(func(e Event) { got = append(got, e) })
+	x.Add("abcdefg", "value1")
+	x.Add("abcdefg", "value2")
+	x.SetTopology("abcdefg", Topology{Zone: "a"})
+	x.SetTopology("missing", Topology{Zone: "a"})
+	x.Remove("abcdefg")
+	x.Remove("abcdefg")
+	x.Set(map[string]interface{}{"hijklmn": nil})
+
+	want := []struct {
+		typ EventType
+		key string
+	}{
+		{MemberAdded, "abcdefg"},
+		{MemberUpdated, "abcdefg"},
+		{MemberUpdated, "abcdefg"},
+		{MemberRemoved, "abcdefg"},
+		{RingReset, ""},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d events, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		if got[i].Type != w.typ || got[i].Key != w.key || got[i].Version != uint64(i+1) {
+			t.Errorf("event %d = %v %q v%d, want %v %q v%d", i, got[i].Type, got[i].Key, got[i].Version, w.typ, w.key, i+1)
+		}
+	}
+	if got[1].Old.Value != "value1" || got[1].Element.Value != "value2" {
+		t.Errorf("unexpected update %v -> %v", got[1].Old.Value, got[1].Element.Value)
+	}
+	if got[3].Old.Topology.Zone != "a" || got[3].Element != nil {
+		t.Errorf("unexpected removal %+v", got[3])
+	}
+
+	cancel()
+	x.Add("opqrstu", nil)
+	checkNum(len(got), len(want), t)
+}
+
+func TestSubscribeChan(t *testing.T) {
+	x := New()
+	ch := make(chan Event, 4)
+	defer x.SubscribeChan(ch)()
+	x.Add("abcdefg", nil)
+	data, err := x.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := x.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if e := <-ch; e.Type != MemberAdded || e.Version != 1 {
+		t.Errorf("unexpected event %v v%d", e.Type, e.Version)
+	}
+	if e := <-ch; e.Type != RingReset || e.Version != 2 {
+		t.Errorf("unexpected event %v v%d", e.Type, e.Version)
+	}
+}
+
+func TestSubscribeReentrant(t *testing.T) {
+	x := New()
+	var got []EventType
+	x.Subscribe(func(e Event) {
+		got = append(got, e.Type)
+		// the write lock is released during delivery
+		if e.Type == MemberAdded && e.Key == "abcdefg" {
+			x.Remove("abcdefg")
+			checkNum(len(got), 1, t)
+		}
+	})
+	x.Add("abcdefg", nil)
+	if len(got) != 2 || got[1] != MemberRemoved {
+		t.Errorf("unexpected events %v", got)
+	}
+}
+
+func TestSubscribeLater(t *testing.T) {
+	x := New()
+	var late []Event
+	x.Subscribe(func(e Event) {
+		if e.Type == MemberAdded && e.Key == "abcdefg" {
+			// the removal is queued before the subscription
+			x.Remove("abcdefg")
+			x.Subscribe(func(e Event) { late = append(late, e) })
+		}
+	})
+	x.Add("abcdefg", nil)
+	x.Add("hijklmn", nil)
+	if len(late) != 1 || late[0].Key != "hijklmn" {
+		t.Errorf("unexpected events %v", late)
+	}
+}
+
+func TestSubscribeCancelDuringDelivery(t *testing.T) {
+	x := New()
+	var cancel func()
+	x.Subscribe(func(e Event) { cancel() })
+	cancel = x.Subscribe(func(e Event) { t.Errorf("unexpected event %v after cancel", e.Type) })
+	x.Add("abcdefg", nil)
+}
+
+func TestSubscribeReweigh(t *testing.T) {
+	x := New()
+	x.AddWeighted("abcdefg", nil, 1)
+	var got []Event
+	x.Subscribe(func(e Event) { got = append(got, e) })
+	x.AddWeighted("hijklmn", nil, 3)
+	if len(got) != 2 {
+		t.Fatalf("got %d events, want 2", len(got))
+	}
+	if got[0].Type != MemberAdded || got[0].Key != "hijklmn" {
+		t.Errorf("unexpected event %v %q", got[0].Type, got[0].Key)
+	}
+	if got[1].Type != MemberUpdated || got[1].Key != "abcdefg" ||
+		got[1].Old.Replica != 20 || got[1].Element.Replica != 10 || got[1].Version != got[0].Version {
+		t.Errorf("unexpected event %+v", got[1])
+	}
+}
+
+func TestSubscribeConcurrent(t *testing.T) {
+	x := New()
+	var last uint64
+	var count int
+	x.Subscribe(func(e Event) {
+		if e.Version <= last {
+			t.Errorf("event v%d delivered after v%d", e.Version, last)
+		}
+		last = e.Version
+		count++
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				x.Add(strconv.Itoa(i)+"-"+strconv.Itoa(j), nil)
+			}
+		}(i)
+	}
+	wg.Wait()
+	checkNum(count, 400, t)
+	checkNum(int(last), 400, t)
+}
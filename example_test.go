@@ -32,7 +32,7 @@ func ExampleNew() {
 	// raw-5 => valueC
 }
 
-func ExampleAdd() {
+func ExampleConsistent_Add() {
 	c := consistent.New()
 	c.Add("keyA", "valueA")
 	c.Add("keyB", "valueB")
@@ -58,7 +58,7 @@ func ExampleAdd() {
 	}
 }
 
-func ExampleRemove() {
+func ExampleConsistent_Remove() {
 	c := consistent.New()
 	c.Add("keyA", "valueA")
 	c.Add("keyB", "valueB")
@@ -82,3 +82,28 @@ func ExampleRemove() {
 		fmt.Printf("%s => %s\n", u, server.Key)
 	}
 }
+
+func ExampleConsistent_Snapshot() {
+	c := consistent.New()
+	c.Add("keyA", "valueA")
+	c.Add("keyB", "valueB")
+	c.Add("keyC", "valueC")
+	snap := c.Snapshot()
+	c.Remove("keyC")
+	users := []string{"raw-1", "raw-2", "raw-3", "raw-4", "raw-5"}
+	for _, u := range users {
+		server, err := snap.Get(u)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%s => %s\n", u, server.Value)
+	}
+	fmt.Println("version", snap.Version(), "members", snap.Len())
+	// Output:
+	// raw-1 => valueC
+	// raw-2 => valueA
+	// raw-3 => valueA
+	// raw-4 => valueC
+	// raw-5 => valueC
+	// version 3 members 3
+}
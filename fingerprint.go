@@ -0,0 +1,60 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+package consistent
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+	"strconv"
+)
+
+// Epoch returns the version of the circle. It increases by one on every
+// Add, Remove, Set and other change of the members.
+func (c *Consistent) Epoch() uint64 {
+	return c.load().version
+}
+
+// Fingerprint returns a digest of the placement of the circle: its members
+// and their replica counts, the hash function and the virtual node key
+// scheme. Two circles with the same fingerprint place every key the same
+// way, so nodes can compare fingerprints to detect a missed update. Element
+// values and the epoch do not affect the fingerprint. Hashers that are not
+// shipped with this package cannot be told apart.
+func (c *Consistent) Fingerprint() uint64 {
+	return c.Snapshot().Fingerprint()
+}
+
+// Fingerprint returns the fingerprint of the circle captured by the snapshot.
+func (s Snapshot) Fingerprint() uint64 {
+	name := hasherName(s.c.hasher)
+	if name == "" {
+		name = "custom"
+	}
+	elems := make([]*Element, 0, s.v.count)
+	for _, e := range s.v.elems {
+		if e != nil {
+			elems = append(elems, e)
+		}
+	}
+	sort.Slice(elems, func(i, j int) bool { return elems[i].Key < elems[j].Key })
+
+	d := sha256.New()
+	var buf []byte
+	field := func(s string) {
+		buf = strconv.AppendInt(buf[:0], int64(len(s)), 10)
+		buf = append(buf, ':')
+		buf = append(buf, s...)
+		d.Write(buf)
+	}
+	field(name)
+	field(strconv.Itoa(s.c.bits()))
+	field(s.c.scheme.Name())
+	for _, e := range elems {
+		field(e.Key)
+		field(strconv.Itoa(e.Replica))
+	}
+	return binary.BigEndian.Uint64(d.Sum(nil))
+}
@@ -0,0 +1,54 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+package consistent
+
+import (
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	x := New()
+	x.Add("abcdefg", "value1")
+	x.Add("hijklmn", "value2")
+	x.AddReplicas("opqrstu", "value3", 5)
+
+	y := New()
+	y.Set(map[string]interface{}{"opqrstu": nil, "hijklmn": nil})
+	y.Add("abcdefg", nil)
+	y.Remove("opqrstu")
+	y.AddReplicas("opqrstu", nil, 5)
+	if x.Fingerprint() != y.Fingerprint() {
+		t.Errorf("expected equal fingerprints for the same members")
+	}
+
+	for _, z := range []*Consistent{
+		func() *Consistent { z := x.Clone(); z.Remove("hijklmn"); return z }(),
+		func() *Consistent { z := x.Clone(); z.AddReplicas("opqrstu", nil, 6); return z }(),
+		func() *Consistent { z := New64(FNV1a); z.Set(x.Members()); return z }(),
+		func() *Consistent { z := New(WithHasher(FNV1a)); z.Set(x.Members()); return z }(),
+	} {
+		if z.Fingerprint() == x.Fingerprint() {
+			t.Errorf("expected a different fingerprint")
+		}
+	}
+}
+
+func TestEpoch(t *testing.T) {
+	x := New()
+	checkNum(int(x.Epoch()), 0, t)
+	x.Add("abcdefg", "value1")
+	x.Add("hijklmn", "value2")
+	checkNum(int(x.Epoch()), 2, t)
+	x.Remove("abcdefg")
+	checkNum(int(x.Epoch()), 3, t)
+	x.Set(map[string]interface{}{"opqrstu": "value3"})
+	checkNum(int(x.Epoch()), 4, t)
+	fp := x.Fingerprint()
+	x.Add("opqrstu", "value4")
+	checkNum(int(x.Epoch()), 5, t)
+	if x.Fingerprint() != fp {
+		t.Errorf("expected values not to change the fingerprint")
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+// Package generic provides a type-safe consistent hash on top of package
+// consistent. Values are stored as T, so lookups never need a type assertion.
+package generic
+
+import (
+	consistent "github.com/zhvala/goconsistent"
+)
+
+// Element contains key、value、replica、weight and topology of a member.
+type Element[T any] struct {
+	Key      string
+	Value    T
+	Replica  int
+	Weight   float64
+	Topology consistent.Topology
+}
+
+// Consistent is a consistent hash whose members hold values of type T.
+type Consistent[T any] struct {
+	c *consistent.Consistent
+}
+
+// New creates a new Consistent object configured with opts, see consistent.New.
+func New[T any](opts ...consistent.Option) *Consistent[T] {
+	return &Consistent[T]{c: consistent.New(opts...)}
+}
+
+// Untyped returns the underlying consistent hash, which gives access to the
+// rest of the API such as snapshots and bounded-load lookups. Values that are
+// not of type T, added through it or decoded by a ValueCodec, read back as the
+// zero T.
+func (c *Consistent[T]) Untyped() *consistent.Consistent {
+	return c.c
+}
+
+// Add inserts a element in the consistent hash.
+func (c *Consistent[T]) Add(key string, value T) {
+	c.c.Add(key, value)
+}
+
+// AddReplicas inserts a element with replica number in the consistent hash.
+func (c *Consistent[T]) AddReplicas(key string, value T, replica int) {
+	c.c.AddReplicas(key, value, replica)
+}
+
+// AddWeighted inserts a element whose share of the circle is proportional to weight.
+func (c *Consistent[T]) AddWeighted(key string, value T, weight float64) {
+	c.c.AddWeighted(key, value, weight)
+}
+
+// Remove removes an element from the hash.
+func (c *Consistent[T]) Remove(key string) {
+	c.c.Remove(key)
+}
+
+// Set sets all the elements in the hash.  If there are existing elements not
+// present in kvs, they will be removed.
+func (c *Consistent[T]) Set(kvs map[string]T) {
+	untyped := make(map[string]interface{}, len(kvs))
+	for k, v := range kvs {
+		untyped[k] = v
+	}
+	c.c.Set(untyped)
+}
+
+// Len returns the number of members in consistent hash.
+func (c *Consistent[T]) Len() int {
+	return c.c.Len()
+}
+
+// Members return all members in consistent hash.
+func (c *Consistent[T]) Members() map[string]Element[T] {
+	untyped := c.c.Members()
+	members := make(map[string]Element[T], len(untyped))
+	for k, v := range untyped {
+		members[k] = typed[T](v.(*consistent.Element))
+	}
+	return members
+}
+
+// Get returns an element close to where name hashes to in the circle.
+func (c *Consistent[T]) Get(raw string) (Element[T], error) {
+	e, err := c.c.Get(raw)
+	if err != nil {
+		return Element[T]{}, err
+	}
+	return typed[T](e), nil
+}
+
+// GetTwo returns the two closest distinct elements to the name input in the
+// circle. The second element is the zero Element when there is only one member.
+func (c *Consistent[T]) GetTwo(raw string) (Element[T], Element[T], error) {
+	a, b, err := c.c.GetTwo(raw)
+	if err != nil {
+		return Element[T]{}, Element[T]{}, err
+	}
+	if b == nil {
+		return typed[T](a), Element[T]{}, nil
+	}
+	return typed[T](a), typed[T](b), nil
+}
+
+// GetN returns the N closest distinct elements to the name input in the circle.
+func (c *Consistent[T]) GetN(raw string, n int) ([]Element[T], error) {
+	untyped, err := c.c.GetN(raw, n)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]Element[T], len(untyped))
+	for i, e := range untyped {
+		res[i] = typed[T](e)
+	}
+	return res, nil
+}
+
+// typed converts e to an Element[T]. A value that is not a T, such as a nil
+// interface value or one added through Untyped, becomes the zero T.
+func typed[T any](e *consistent.Element) Element[T] {
+	value, _ := e.Value.(T)
+	return Element[T]{
+		Key:      e.Key,
+		Value:    value,
+		Replica:  e.Replica,
+		Weight:   e.Weight,
+		Topology: e.Topology,
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+package generic
+
+import (
+	"testing"
+
+	consistent "github.com/zhvala/goconsistent"
+)
+
+type server struct {
+	addr string
+	port int
+}
+
+func TestGetEmpty(t *testing.T) {
+	x := New[int]()
+	if _, err := x.Get("abcdefg"); err != consistent.ErrEmptyCircle {
+		t.Errorf("expected empty circle error")
+	}
+	if _, _, err := x.GetTwo("abcdefg"); err != consistent.ErrEmptyCircle {
+		t.Errorf("expected empty circle error")
+	}
+	if _, err := x.GetN("abcdefg", 2); err != consistent.ErrEmptyCircle {
+		t.Errorf("expected empty circle error")
+	}
+}
+
+func TestTypedLookups(t *testing.T) {
+	x := New[server]()
+	x.Add("abcdefg", server{"10.0.0.1", 80})
+	x.AddReplicas("hijklmn", server{"10.0.0.2", 80}, 20)
+	x.AddWeighted("opqrstu", server{"10.0.0.3", 8080}, 1)
+
+	e, err := x.Get("iiiii")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Key != "hijklmn" || e.Value.addr != "10.0.0.2" || e.Replica != 20 || e.Weight != 1 {
+		t.Errorf("unexpected element %+v", e)
+	}
+	a, b, err := x.GetTwo("99999999")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Key != "abcdefg" || b.Key != "hijklmn" || b.Value.port != 80 {
+		t.Errorf("unexpected pair %+v %+v", a, b)
+	}
+	members, err := x.GetN("9999999", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 3 || members[0].Value.port != 8080 {
+		t.Errorf("unexpected members %+v", members)
+	}
+	if x.Members()["opqrstu"].Value.addr != "10.0.0.3" {
+		t.Errorf("unexpected members %+v", x.Members())
+	}
+}
+
+func TestSetRemove(t *testing.T) {
+	x := New[*server](consistent.WithHasher(consistent.FNV1a))
+	x.Set(map[string]*server{"jkl": {"10.0.0.4", 80}, "mno": {"10.0.0.5", 80}})
+	if x.Len() != 2 {
+		t.Errorf("expected 2 members, got %d", x.Len())
+	}
+	x.Remove("jkl")
+	a, b, err := x.GetTwo("qwerqwerwqer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Key != "mno" || a.Value.addr != "10.0.0.5" || b.Key != "" || b.Value != nil {
+		t.Errorf("unexpected pair %+v %+v", a, b)
+	}
+	if x.Untyped().Len() != 1 {
+		t.Errorf("expected untyped view to share members")
+	}
+}
+
+func TestMismatchedValues(t *testing.T) {
+	x := New[error]()
+	x.Add("abcdefg", nil)
+	e, err := x.Get("abcdefg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Key != "abcdefg" || e.Value != nil {
+		t.Errorf("unexpected element %+v", e)
+	}
+
+	y := New[int]()
+	y.Untyped().Add("abcdefg", "not an int")
+	f, err := y.Get("abcdefg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Value != 0 {
+		t.Errorf("expected the zero value, got %v", f.Value)
+	}
+	if m := y.Members()["abcdefg"]; m.Key != "abcdefg" || m.Value != 0 {
+		t.Errorf("unexpected member %+v", m)
+	}
+}
@@ -0,0 +1,465 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+package consistent
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"hash/crc32"
+	"math/bits"
+)
+
+// Hasher maps a key to a point on the hash circle.
+//
+// Implementations must be deterministic and safe for concurrent use.
+type Hasher interface {
+	Sum32(data []byte) uint32
+}
+
+// Hasher64 is a Hasher that can also produce 64-bit points. It is required
+// by rings created with WithHasher64.
+type Hasher64 interface {
+	Hasher
+	Sum64(data []byte) uint64
+}
+
+// HasherFunc adapts an ordinary function to the Hasher interface.
+type HasherFunc func(data []byte) uint32
+
+// Sum32 returns f(data).
+func (f HasherFunc) Sum32(data []byte) uint32 { return f(data) }
+
+var (
+	// CRC32 hashes keys with the IEEE crc32 checksum. It is the default hasher.
+	CRC32 Hasher = crc32Hasher{}
+	// FNV1a hashes keys with FNV-1a.
+	FNV1a Hasher64 = fnv1aHasher{}
+	// XXHash hashes keys with xxHash (XXH32/XXH64, seed 0).
+	XXHash Hasher64 = xxHasher{}
+	// Murmur3 hashes keys with MurmurHash3 (x86_32 and the first half of x64_128, seed 0).
+	Murmur3 Hasher64 = murmur3Hasher{}
+	// SipHash hashes keys with SipHash-2-4 using an all-zero key.
+	SipHash Hasher64 = NewSipHash(0, 0)
+	// MD5 hashes keys to the first four bytes of their MD5 digest read as a
+	// little-endian integer, like ketama clients.
+	MD5 Hasher = md5Hasher{}
+)
+
+type crc32Hasher struct{}
+
+func (crc32Hasher) Sum32(data []byte) uint32 { return crc32.ChecksumIEEE(data) }
+
+// crc32Short computes crc32.ChecksumIEEE byte by byte. It is slower on long
+// input but, unlike the accelerated version, does not make data escape.
+func crc32Short(data []byte) uint32 {
+	crc := ^uint32(0)
+	for _, b := range data {
+		crc = crc32.IEEETable[byte(crc)^b] ^ crc>>8
+	}
+	return ^crc
+}
+
+type md5Hasher struct{}
+
+func (md5Hasher) Sum32(data []byte) uint32 {
+	digest := md5.Sum(data)
+	return binary.LittleEndian.Uint32(digest[:])
+}
+
+const (
+	fnv32Offset = 2166136261
+	fnv32Prime  = 16777619
+	fnv64Offset = 14695981039346656037
+	fnv64Prime  = 1099511628211
+)
+
+type fnv1aHasher struct{}
+
+func (fnv1aHasher) Sum32(data []byte) uint32 {
+	h := uint32(fnv32Offset)
+	for _, b := range data {
+		h ^= uint32(b)
+		h *= fnv32Prime
+	}
+	return h
+}
+
+func (fnv1aHasher) Sum64(data []byte) uint64 {
+	h := uint64(fnv64Offset)
+	for _, b := range data {
+		h ^= uint64(b)
+		h *= fnv64Prime
+	}
+	return h
+}
+
+const (
+	xxPrime32_1 = 2654435761
+	xxPrime32_2 = 2246822519
+	xxPrime32_3 = 3266489917
+	xxPrime32_4 = 668265263
+	xxPrime32_5 = 374761393
+
+	xxPrime64_1 = 11400714785074694791
+	xxPrime64_2 = 14029467366897019727
+	xxPrime64_3 = 1609587929392839161
+	xxPrime64_4 = 9650029242287828579
+	xxPrime64_5 = 2870177450012600261
+)
+
+type xxHasher struct{}
+
+func (xxHasher) Sum32(data []byte) uint32 {
+	n := len(data)
+	var h uint32
+	if n >= 16 {
+		var v1, v2, v3, v4 uint32
+		v1 = xxPrime32_1
+		v1 += xxPrime32_2
+		v2 = xxPrime32_2
+		v4 -= xxPrime32_1
+		for len(data) >= 16 {
+			v1 = xxRound32(v1, binary.LittleEndian.Uint32(data[0:]))
+			v2 = xxRound32(v2, binary.LittleEndian.Uint32(data[4:]))
+			v3 = xxRound32(v3, binary.LittleEndian.Uint32(data[8:]))
+			v4 = xxRound32(v4, binary.LittleEndian.Uint32(data[12:]))
+			data = data[16:]
+		}
+		h = bits.RotateLeft32(v1, 1) + bits.RotateLeft32(v2, 7) +
+			bits.RotateLeft32(v3, 12) + bits.RotateLeft32(v4, 18)
+	} else {
+		h = xxPrime32_5
+	}
+	h += uint32(n)
+	for len(data) >= 4 {
+		h += binary.LittleEndian.Uint32(data) * xxPrime32_3
+		h = bits.RotateLeft32(h, 17) * xxPrime32_4
+		data = data[4:]
+	}
+	for _, b := range data {
+		h += uint32(b) * xxPrime32_5
+		h = bits.RotateLeft32(h, 11) * xxPrime32_1
+	}
+	h ^= h >> 15
+	h *= xxPrime32_2
+	h ^= h >> 13
+	h *= xxPrime32_3
+	h ^= h >> 16
+	return h
+}
+
+func xxRound32(acc, input uint32) uint32 {
+	acc += input * xxPrime32_2
+	acc = bits.RotateLeft32(acc, 13)
+	return acc * xxPrime32_1
+}
+
+func (xxHasher) Sum64(data []byte) uint64 {
+	n := len(data)
+	var h uint64
+	if n >= 32 {
+		var v1, v2, v3, v4 uint64
+		v1 = xxPrime64_1
+		v1 += xxPrime64_2
+		v2 = xxPrime64_2
+		v4 -= xxPrime64_1
+		for len(data) >= 32 {
+			v1 = xxRound64(v1, binary.LittleEndian.Uint64(data[0:]))
+			v2 = xxRound64(v2, binary.LittleEndian.Uint64(data[8:]))
+			v3 = xxRound64(v3, binary.LittleEndian.Uint64(data[16:]))
+			v4 = xxRound64(v4, binary.LittleEndian.Uint64(data[24:]))
+			data = data[32:]
+		}
+		h = bits.RotateLeft64(v1, 1) + bits.RotateLeft64(v2, 7) +
+			bits.RotateLeft64(v3, 12) + bits.RotateLeft64(v4, 18)
+		h = xxMerge64(h, v1)
+		h = xxMerge64(h, v2)
+		h = xxMerge64(h, v3)
+		h = xxMerge64(h, v4)
+	} else {
+		h = xxPrime64_5
+	}
+	h += uint64(n)
+	for len(data) >= 8 {
+		h ^= xxRound64(0, binary.LittleEndian.Uint64(data))
+		h = bits.RotateLeft64(h, 27)*xxPrime64_1 + xxPrime64_4
+		data = data[8:]
+	}
+	if len(data) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(data)) * xxPrime64_1
+		h = bits.RotateLeft64(h, 23)*xxPrime64_2 + xxPrime64_3
+		data = data[4:]
+	}
+	for _, b := range data {
+		h ^= uint64(b) * xxPrime64_5
+		h = bits.RotateLeft64(h, 11) * xxPrime64_1
+	}
+	h ^= h >> 33
+	h *= xxPrime64_2
+	h ^= h >> 29
+	h *= xxPrime64_3
+	h ^= h >> 32
+	return h
+}
+
+func xxRound64(acc, input uint64) uint64 {
+	acc += input * xxPrime64_2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxPrime64_1
+}
+
+func xxMerge64(acc, val uint64) uint64 {
+	acc ^= xxRound64(0, val)
+	return acc*xxPrime64_1 + xxPrime64_4
+}
+
+const (
+	murmurC1 = 0xcc9e2d51
+	murmurC2 = 0x1b873593
+)
+
+type murmur3Hasher struct{}
+
+func (murmur3Hasher) Sum32(data []byte) uint32 {
+	var h uint32
+	n := len(data)
+	for len(data) >= 4 {
+		k := binary.LittleEndian.Uint32(data)
+		k *= murmurC1
+		k = bits.RotateLeft32(k, 15)
+		k *= murmurC2
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+		data = data[4:]
+	}
+	var k uint32
+	switch len(data) {
+	case 3:
+		k ^= uint32(data[2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(data[1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(data[0])
+		k *= murmurC1
+		k = bits.RotateLeft32(k, 15)
+		k *= murmurC2
+		h ^= k
+	}
+	h ^= uint32(n)
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
+
+const (
+	murmurC1_64 = 0x87c37b91114253d5
+	murmurC2_64 = 0x4cf5ad432745937f
+)
+
+// Sum64 returns the first 64 bits of MurmurHash3 x64_128.
+func (murmur3Hasher) Sum64(data []byte) uint64 {
+	var h1, h2 uint64
+	n := len(data)
+	for len(data) >= 16 {
+		k1 := binary.LittleEndian.Uint64(data)
+		k2 := binary.LittleEndian.Uint64(data[8:])
+		h1 ^= murmurMix64(k1, murmurC1_64, 31, murmurC2_64)
+		h1 = bits.RotateLeft64(h1, 27)
+		h1 += h2
+		h1 = h1*5 + 0x52dce729
+		h2 ^= murmurMix64(k2, murmurC2_64, 33, murmurC1_64)
+		h2 = bits.RotateLeft64(h2, 31)
+		h2 += h1
+		h2 = h2*5 + 0x38495ab5
+		data = data[16:]
+	}
+	var k1, k2 uint64
+	switch {
+	case len(data) > 8:
+		for i := len(data) - 1; i >= 8; i-- {
+			k2 = k2<<8 | uint64(data[i])
+		}
+		h2 ^= murmurMix64(k2, murmurC2_64, 33, murmurC1_64)
+		k1 = binary.LittleEndian.Uint64(data)
+		h1 ^= murmurMix64(k1, murmurC1_64, 31, murmurC2_64)
+	case len(data) > 0:
+		for i := len(data) - 1; i >= 0; i-- {
+			k1 = k1<<8 | uint64(data[i])
+		}
+		h1 ^= murmurMix64(k1, murmurC1_64, 31, murmurC2_64)
+	}
+	h1 ^= uint64(n)
+	h2 ^= uint64(n)
+	h1 += h2
+	h2 += h1
+	h1 = murmurFmix64(h1)
+	h2 = murmurFmix64(h2)
+	h1 += h2
+	return h1
+}
+
+func murmurMix64(k, c1 uint64, r int, c2 uint64) uint64 {
+	k *= c1
+	k = bits.RotateLeft64(k, r)
+	return k * c2
+}
+
+func murmurFmix64(k uint64) uint64 {
+	k ^= k >> 33
+	k *= 0xff51afd7ed558ccd
+	k ^= k >> 33
+	k *= 0xc4ceb9fe1a85ec53
+	k ^= k >> 33
+	return k
+}
+
+// SipHasher hashes keys with SipHash-2-4 under a 128-bit key.
+type SipHasher struct {
+	k0, k1 uint64
+}
+
+// NewSipHash returns a SipHash-2-4 hasher keyed with k0 and k1.
+func NewSipHash(k0, k1 uint64) *SipHasher {
+	return &SipHasher{k0: k0, k1: k1}
+}
+
+// Sum32 returns the 64-bit SipHash-2-4 digest folded to 32 bits.
+func (s *SipHasher) Sum32(data []byte) uint32 {
+	h := s.Sum64(data)
+	return uint32(h) ^ uint32(h>>32)
+}
+
+// Sum64 returns the 64-bit SipHash-2-4 digest.
+func (s *SipHasher) Sum64(data []byte) uint64 {
+	v0 := s.k0 ^ 0x736f6d6570736575
+	v1 := s.k1 ^ 0x646f72616e646f6d
+	v2 := s.k0 ^ 0x6c7967656e657261
+	v3 := s.k1 ^ 0x7465646279746573
+	b := uint64(len(data)) << 56
+	for len(data) >= 8 {
+		m := binary.LittleEndian.Uint64(data)
+		v3 ^= m
+		v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+		v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+		v0 ^= m
+		data = data[8:]
+	}
+	for i, c := range data {
+		b |= uint64(c) << (8 * uint(i))
+	}
+	v3 ^= b
+	v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+	v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+	v0 ^= b
+	v2 ^= 0xff
+	for i := 0; i < 4; i++ {
+		v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+	}
+	return v0 ^ v1 ^ v2 ^ v3
+}
+
+func sipRound(v0, v1, v2, v3 uint64) (uint64, uint64, uint64, uint64) {
+	v0 += v1
+	v1 = bits.RotateLeft64(v1, 13)
+	v1 ^= v0
+	v0 = bits.RotateLeft64(v0, 32)
+	v2 += v3
+	v3 = bits.RotateLeft64(v3, 16)
+	v3 ^= v2
+	v0 += v3
+	v3 = bits.RotateLeft64(v3, 21)
+	v3 ^= v0
+	v2 += v1
+	v1 = bits.RotateLeft64(v1, 17)
+	v1 ^= v2
+	v2 = bits.RotateLeft64(v2, 32)
+	return v0, v1, v2, v3
+}
+
+// builtinSum hashes data with h if h is one of the hashers shipped with this
+// package. Calling the concrete types directly lets the compiler keep data
+// on the stack, which a call through the Hasher interface cannot. These
+// hashers only read data.
+func builtinSum(h Hasher, wide bool, data []byte) (uint64, bool) {
+	switch h := h.(type) {
+	case nil, crc32Hasher:
+		// a zero Consistent hashes like New would
+		if !wide {
+			return uint64(crc32Short(data)), true
+		}
+	case md5Hasher:
+		if !wide {
+			return uint64(h.Sum32(data)), true
+		}
+	case fnv1aHasher:
+		if wide {
+			return h.Sum64(data), true
+		}
+		return uint64(h.Sum32(data)), true
+	case xxHasher:
+		if wide {
+			return h.Sum64(data), true
+		}
+		return uint64(h.Sum32(data)), true
+	case murmur3Hasher:
+		if wide {
+			return h.Sum64(data), true
+		}
+		return uint64(h.Sum32(data)), true
+	case *SipHasher:
+		if wide {
+			return h.Sum64(data), true
+		}
+		return uint64(h.Sum32(data)), true
+	}
+	return 0, false
+}
+
+// hasherName returns the name of the hashers shipped with this package, or
+// "" for any other hasher.
+func hasherName(h Hasher) string {
+	switch h := h.(type) {
+	case crc32Hasher:
+		return "crc32"
+	case md5Hasher:
+		return "md5"
+	case fnv1aHasher:
+		return "fnv1a"
+	case xxHasher:
+		return "xxhash"
+	case murmur3Hasher:
+		return "murmur3"
+	case *SipHasher:
+		if h.k0 == 0 && h.k1 == 0 {
+			return "siphash"
+		}
+	}
+	return ""
+}
+
+// hasherByName is the inverse of hasherName.
+func hasherByName(name string) Hasher {
+	switch name {
+	case "crc32":
+		return CRC32
+	case "md5":
+		return MD5
+	case "fnv1a":
+		return FNV1a
+	case "xxhash":
+		return XXHash
+	case "murmur3":
+		return Murmur3
+	case "siphash":
+		return SipHash
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+package consistent
+
+import (
+	"testing"
+)
+
+type htest struct {
+	in  string
+	out uint32
+}
+
+var hasherTests = []struct {
+	name   string
+	hasher Hasher
+	tests  []htest
+}{
+	{"crc32", CRC32, []htest{
+		{"", 0},
+		{"a", 0xe8b7be43},
+		{"123456789", 0xcbf43926},
+	}},
+	{"fnv1a", FNV1a, []htest{
+		{"", 0x811c9dc5},
+		{"a", 0xe40c292c},
+		{"foobar", 0xbf9cf968},
+	}},
+	{"xxhash", XXHash, []htest{
+		{"", 0x02cc5d05},
+		{"a", 0x550d7456},
+		{"abc", 0x32d153ff},
+		{"Nobody inspects the spammish repetition", 0xe2293b2f},
+	}},
+	{"murmur3", Murmur3, []htest{
+		{"", 0},
+		{"hello", 0x248bfa47},
+		{"The quick brown fox jumps over the lazy dog", 0x2e4ff723},
+	}},
+	{"md5", MD5, []htest{
+		{"", 0xd98c1dd4},
+		{"a", 0xb975c10c},
+		{"abc", 0x98500190},
+	}},
+}
+
+func TestHashers(t *testing.T) {
+	for _, ht := range hasherTests {
+		for _, v := range ht.tests {
+			if got := ht.hasher.Sum32([]byte(v.in)); got != v.out {
+				t.Errorf("%s(%q) = %#08x, expected %#08x", ht.name, v.in, got, v.out)
+			}
+		}
+	}
+}
+
+func TestSipHash(t *testing.T) {
+	// reference vectors from the SipHash paper: key 00..0f, message 00..(n-1)
+	h := NewSipHash(0x0706050403020100, 0x0f0e0d0c0b0a0908)
+	msg := make([]byte, 15)
+	for i := range msg {
+		msg[i] = byte(i)
+	}
+	if got := h.Sum64(nil); got != 0x726fdb47dd0e0e31 {
+		t.Errorf("siphash(empty) = %#016x", got)
+	}
+	if got := h.Sum64(msg); got != 0xa129ca6149be45e5 {
+		t.Errorf("siphash(00..0e) = %#016x", got)
+	}
+}
+
+func TestCRC32Short(t *testing.T) {
+	for _, v := range hasherTests[0].tests {
+		if got := crc32Short([]byte(v.in)); got != v.out {
+			t.Errorf("crc32Short(%q) = %#08x, expected %#08x", v.in, got, v.out)
+		}
+	}
+}
+
+func TestHasherFunc(t *testing.T) {
+	h := HasherFunc(func(data []byte) uint32 { return uint32(len(data)) })
+	checkNum(int(h.Sum32([]byte("abcd"))), 4, t)
+}
+
+type htest64 struct {
+	in  string
+	out uint64
+}
+
+var hasher64Tests = []struct {
+	name   string
+	hasher Hasher64
+	tests  []htest64
+}{
+	{"fnv1a", FNV1a, []htest64{
+		{"", 0xcbf29ce484222325},
+		{"a", 0xaf63dc4c8601ec8c},
+		{"foobar", 0x85944171f73967e8},
+	}},
+	{"xxhash", XXHash, []htest64{
+		{"", 0xef46db3751d8e999},
+		{"a", 0xd24ec4f1a98c6e5b},
+		{"abc", 0x44bc2cf5ad770999},
+		{"Nobody inspects the spammish repetition", 0xfbcea83c8a378bf1},
+	}},
+	{"murmur3", Murmur3, []htest64{
+		{"", 0},
+		{"hello", 0xcbd8a7b341bd9b02},
+		{"The quick brown fox jumps over the lazy dog", 0xe34bbc7bbc071b6c},
+	}},
+}
+
+func TestHashers64(t *testing.T) {
+	for _, ht := range hasher64Tests {
+		for _, v := range ht.tests {
+			if got := ht.hasher.Sum64([]byte(v.in)); got != v.out {
+				t.Errorf("%s(%q) = %#016x, expected %#016x", ht.name, v.in, got, v.out)
+			}
+		}
+	}
+}
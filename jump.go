@@ -0,0 +1,159 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+package consistent
+
+import (
+	"sort"
+	"sync"
+)
+
+// Jump implements Jump Consistent Hash ("A Fast, Minimal Memory, Consistent
+// Hash Algorithm" by Lamping and Veach).
+//
+// Elements are numbered buckets 0..N-1 in the order they were added. Jump
+// needs no virtual nodes and spreads keys evenly, but it is designed for
+// growing and shrinking at the end: removing any element but the last one
+// moves the last element into the freed bucket.
+type Jump struct {
+	mu       sync.RWMutex
+	hasher   Hasher64
+	elements []*Element
+	index    map[string]int
+}
+
+// NewJump creates a new Jump object that hashes keys with h.
+func NewJump(h Hasher64) *Jump {
+	return &Jump{
+		hasher: h,
+		index:  make(map[string]int),
+	}
+}
+
+// Add appends an element as the last bucket. Adding an existing key updates
+// its value in place.
+func (j *Jump) Add(key string, value interface{}) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.add(key, value)
+}
+
+// AddReplicas is the same as Add: Jump has no virtual nodes.
+func (j *Jump) AddReplicas(key string, value interface{}, replica int) {
+	j.Add(key, value)
+}
+
+// need j.mu.Lock() before calling
+func (j *Jump) add(key string, value interface{}) {
+	elem := &Element{Key: key, Value: value, Replica: 1, Weight: 1}
+	if i, ok := j.index[key]; ok {
+		j.elements[i] = elem
+		return
+	}
+	j.index[key] = len(j.elements)
+	j.elements = append(j.elements, elem)
+}
+
+// Remove removes an element. If it is not the last bucket, the last element
+// takes over its bucket.
+func (j *Jump) Remove(key string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	i, ok := j.index[key]
+	if !ok {
+		return
+	}
+	last := len(j.elements) - 1
+	if i != last {
+		j.elements[i] = j.elements[last]
+		j.index[j.elements[i].Key] = i
+	}
+	j.elements[last] = nil
+	j.elements = j.elements[:last]
+	delete(j.index, key)
+}
+
+// Set sets all the elements. Buckets are assigned in key order so that every
+// process calling Set with the same elements agrees on the placement.
+func (j *Jump) Set(kvs map[string]interface{}) {
+	keys := make([]string, 0, len(kvs))
+	for k := range kvs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.elements = make([]*Element, 0, len(keys))
+	j.index = make(map[string]int, len(keys))
+	for _, k := range keys {
+		j.add(k, kvs[k])
+	}
+}
+
+// Members return all members.
+func (j *Jump) Members() map[string]interface{} {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	members := make(map[string]interface{}, len(j.elements))
+	for _, e := range j.elements {
+		members[e.Key] = e
+	}
+	return members
+}
+
+// Len returns the number of members.
+func (j *Jump) Len() int {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return len(j.elements)
+}
+
+// Get returns the element owning the bucket raw jumps to.
+func (j *Jump) Get(raw string) (*Element, error) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	if len(j.elements) == 0 {
+		return nil, ErrEmptyCircle
+	}
+	return j.elements[jumpHash(j.hasher.Sum64([]byte(raw)), len(j.elements))], nil
+}
+
+// GetTwo returns the elements of the bucket raw jumps to and of the next bucket.
+func (j *Jump) GetTwo(raw string) (*Element, *Element, error) {
+	return getTwo(j.GetN, raw)
+}
+
+// GetN returns the elements of the bucket raw jumps to and of the n-1
+// buckets following it.
+func (j *Jump) GetN(raw string, n int) ([]*Element, error) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	if len(j.elements) == 0 {
+		return nil, ErrEmptyCircle
+	}
+	if len(j.elements) < n {
+		n = len(j.elements)
+	}
+	if n < 0 {
+		n = 0
+	}
+	b := jumpHash(j.hasher.Sum64([]byte(raw)), len(j.elements))
+	res := make([]*Element, n)
+	for i := range res {
+		res[i] = j.elements[(b+i)%len(j.elements)]
+	}
+	return res, nil
+}
+
+// jumpHash maps key to a bucket in [0, buckets).
+func jumpHash(key uint64, buckets int) int {
+	var b, j int64 = -1, 0
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
@@ -0,0 +1,111 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+package consistent
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestJumpHash(t *testing.T) {
+	for key := uint64(0); key < 1000; key++ {
+		checkNum(jumpHash(key, 1), 0, t)
+		prev := 0
+		for n := 1; n < 50; n++ {
+			b := jumpHash(key, n)
+			if b < 0 || b >= n {
+				t.Fatalf("jumpHash(%d, %d) = %d out of range", key, n, b)
+			}
+			// growing by one bucket only ever moves keys to the new bucket
+			if b != prev && b != n-1 {
+				t.Fatalf("jumpHash(%d, %d) = %d, was %d", key, n, b, prev)
+			}
+			prev = b
+		}
+	}
+}
+
+func TestJumpGetEmpty(t *testing.T) {
+	x := NewJump(FNV1a)
+	if _, err := x.Get("abcdefg"); err != ErrEmptyCircle {
+		t.Errorf("expected empty circle error")
+	}
+	if _, _, err := x.GetTwo("abcdefg"); err != ErrEmptyCircle {
+		t.Errorf("expected empty circle error")
+	}
+}
+
+func TestJumpAddRemove(t *testing.T) {
+	x := NewJump(FNV1a)
+	x.Add("abcdefg", "value1")
+	x.Add("hijklmn", "value2")
+	x.Add("opqrstu", "value3")
+	checkNum(x.Len(), 3, t)
+	before := make(map[string]string)
+	for i := 0; i < 1000; i++ {
+		e, err := x.Get(strconv.Itoa(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		before[strconv.Itoa(i)] = e.Key
+	}
+	x.Add("vwxyz", "value4")
+	counts := make(map[string]int)
+	for k, old := range before {
+		e, _ := x.Get(k)
+		if e.Key != old && e.Key != "vwxyz" {
+			t.Errorf("%s moved from %q to %q", k, old, e.Key)
+		}
+		counts[e.Key]++
+	}
+	for key, n := range counts {
+		if n < 150 || n > 350 {
+			t.Errorf("%s: uneven load %d", key, n)
+		}
+	}
+
+	x.Remove("hijklmn")
+	checkNum(x.Len(), 3, t)
+	if _, ok := x.Members()["hijklmn"]; ok {
+		t.Errorf("expected hijklmn to be removed")
+	}
+	checkNum(x.index["vwxyz"], 1, t)
+	a, b, err := x.GetTwo("abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b || a.Key == "hijklmn" || b.Key == "hijklmn" {
+		t.Errorf("unexpected pair %q %q", a.Key, b.Key)
+	}
+	members, err := x.GetN("abc", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkNum(len(members), 3, t)
+	if members[0] != a || members[1] != b {
+		t.Errorf("GetTwo and GetN disagree")
+	}
+}
+
+func TestJumpSet(t *testing.T) {
+	x := NewJump(XXHash)
+	y := NewJump(XXHash)
+	kvs := map[string]interface{}{"abc": 1, "def": 2, "ghi": 3, "jkl": 4}
+	x.Set(kvs)
+	y.Set(kvs)
+	checkNum(x.Len(), 4, t)
+	for i := 0; i < 100; i++ {
+		a, _ := x.Get(strconv.Itoa(i))
+		b, _ := y.Get(strconv.Itoa(i))
+		if a.Key != b.Key {
+			t.Errorf("Set is not deterministic")
+		}
+	}
+	x.Add("abc", 5)
+	checkNum(x.Len(), 4, t)
+	if x.elements[0].Value != 5 {
+		t.Errorf("expected value to be updated")
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+package consistent
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// KetamaPoints is the number of points a server gets in a ketama ring
+	// of servers of equal weight.
+	KetamaPoints = 160
+	// KetamaDefaultPort is the memcached port left out of the virtual node
+	// labels of the Libmemcached scheme.
+	KetamaDefaultPort = "11211"
+)
+
+// Libmemcached is the Ketama scheme with the labels of libmemcached: the
+// ":11211" suffix of servers named "host:11211" is dropped, so their virtual
+// nodes are labelled "host-i" while other servers keep "host:port-i".
+var Libmemcached KeyScheme = ketamaScheme{libmemcached: true}
+
+// KetamaRing is a ring that places keys like the weighted ketama distribution
+// of libmemcached, so that Go services agree key for key with C and PHP
+// memcached clients. Members are servers named "host:port". Keys and
+// virtual nodes are hashed with MD5 and every server gets a number of points
+// proportional to its share of the total weight, so any change recomputes
+// the points of every server, as ketama does.
+type KetamaRing struct {
+	mu      sync.Mutex
+	c       *Consistent
+	weights map[string]float64
+	values  map[string]interface{}
+}
+
+// NewKetamaRing creates an empty KetamaRing.
+func NewKetamaRing() *KetamaRing {
+	return &KetamaRing{
+		c:       New(WithHasher(MD5), WithKeyScheme(Libmemcached)),
+		weights: make(map[string]float64),
+		values:  make(map[string]interface{}),
+	}
+}
+
+// Add inserts a server with weight 1.
+func (k *KetamaRing) Add(server string, value interface{}) {
+	k.AddWeighted(server, value, 1)
+}
+
+// AddReplicas inserts a server whose weight is replica relative to
+// DefaultReplicaNumber, matching the share it would get in Consistent.
+func (k *KetamaRing) AddReplicas(server string, value interface{}, replica int) {
+	k.AddWeighted(server, value, float64(replica)/DefaultReplicaNumber)
+}
+
+// AddWeighted inserts a server with the given weight. Servers with a weight
+// <= 0 receive no keys.
+func (k *KetamaRing) AddWeighted(server string, value interface{}, weight float64) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.weights[server] = weight
+	k.values[server] = value
+	k.rebuild()
+}
+
+// Remove removes a server.
+func (k *KetamaRing) Remove(server string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.weights[server]; !ok {
+		return
+	}
+	delete(k.weights, server)
+	delete(k.values, server)
+	k.rebuild()
+}
+
+// Set sets all the servers with weight 1.
+func (k *KetamaRing) Set(kvs map[string]interface{}) {
+	k.SetWeighted(kvs, nil)
+}
+
+// SetWeighted is like Set, but gives every server the weight found in
+// weights. Servers missing from weights get weight 1.
+func (k *KetamaRing) SetWeighted(kvs map[string]interface{}, weights map[string]float64) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.weights = make(map[string]float64, len(kvs))
+	k.values = make(map[string]interface{}, len(kvs))
+	for server, v := range kvs {
+		weight, ok := weights[server]
+		if !ok {
+			weight = 1
+		}
+		k.weights[server] = weight
+		k.values[server] = v
+	}
+	k.rebuild()
+}
+
+// rebuild gives every server its share of the points and publishes the
+// new circle.
+// need k.mu.Lock() before calling
+func (k *KetamaRing) rebuild() {
+	var total float64
+	for _, w := range k.weights {
+		if w > 0 {
+			total += w
+		}
+	}
+	elems := make([]*Element, 0, len(k.weights))
+	for server, w := range k.weights {
+		elems = append(elems, &Element{
+			Key:     server,
+			Value:   k.values[server],
+			Replica: ketamaPoints(w, total, len(k.weights)),
+			Weight:  w,
+		})
+	}
+	k.c.mu.Lock()
+	defer k.c.mu.Unlock()
+	k.c.restore(elems)
+}
+
+// ketamaPoints returns the number of points of a server of the given weight
+// among n servers, computed in single precision like libmemcached.
+func ketamaPoints(weight, total float64, n int) int {
+	if weight <= 0 {
+		return 0
+	}
+	pct := float32(weight) / float32(total)
+	hashes := math.Floor(float64(pct*KetamaPoints/4*float32(n)) + 0.0000000001)
+	return int(hashes) * 4
+}
+
+// Members return all servers.
+func (k *KetamaRing) Members() map[string]interface{} {
+	return k.c.Members()
+}
+
+// Len returns the number of servers.
+func (k *KetamaRing) Len() int {
+	return k.c.Len()
+}
+
+// Get returns the server raw belongs to.
+func (k *KetamaRing) Get(raw string) (*Element, error) {
+	return k.c.Get(raw)
+}
+
+// GetTwo returns the two closest distinct servers to raw on the circle.
+func (k *KetamaRing) GetTwo(raw string) (*Element, *Element, error) {
+	return k.c.GetTwo(raw)
+}
+
+// GetN returns the N closest distinct servers to raw on the circle.
+func (k *KetamaRing) GetN(raw string, n int) ([]*Element, error) {
+	return k.c.GetN(raw, n)
+}
+
+// Snapshot returns a view of the current servers, for use with Diff,
+// Migrate or Fingerprint.
+func (k *KetamaRing) Snapshot() Snapshot {
+	return k.c.Snapshot()
+}
+
+type ketamaScheme struct {
+	libmemcached bool
+}
+
+func (s ketamaScheme) Name() string {
+	if s.libmemcached {
+		return "libmemcached"
+	}
+	return "ketama"
+}
+
+func (s ketamaScheme) Points(dst []uint64, key string, n int, _ func(string) uint64) []uint64 {
+	if s.libmemcached {
+		key = strings.TrimSuffix(key, ":"+KetamaDefaultPort)
+	}
+	for i := 0; n > 0; i++ {
+		digest := md5.Sum([]byte(key + "-" + strconv.Itoa(i)))
+		for j := 0; j < 4 && n > 0; j, n = j+1, n-1 {
+			dst = append(dst, uint64(binary.LittleEndian.Uint32(digest[j*4:])))
+		}
+	}
+	return dst
+}
+
+func (ketamaScheme) Inclusive() bool { return true }
@@ -0,0 +1,143 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+package consistent
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestKetamaPoints(t *testing.T) {
+	// point counts of libmemcached's weighted ketama for weights 1, 1, 2, 3
+	for _, v := range []struct {
+		weight float64
+		points int
+	}{{1, 88}, {2, 180}, {3, 272}} {
+		checkNum(ketamaPoints(v.weight, 7, 4), v.points, t)
+	}
+	for n := 1; n <= 10; n++ {
+		checkNum(ketamaPoints(1, float64(n), n), KetamaPoints, t)
+	}
+	checkNum(ketamaPoints(0, 1, 1), 0, t)
+}
+
+func TestKetamaGolden(t *testing.T) {
+	// placements of the continuum libmemcached builds with
+	// MEMCACHED_BEHAVIOR_KETAMA_WEIGHTED, hashing keys with MD5, for the
+	// weights and the default port label that TestKetamaRecorded does not
+	// cover. They come from testdata/ketama_golden.py, a transcription of
+	// update_continuum and dispatch_host of libmemcached 1.0.18, not from
+	// running libmemcached itself.
+	k := NewKetamaRing()
+	k.SetWeighted(map[string]interface{}{
+		"10.0.1.1:11211":          nil,
+		"10.0.1.2:11211":          nil,
+		"10.0.1.3:11212":          nil,
+		"cache.example.com:11211": nil,
+	}, map[string]float64{"10.0.1.3:11212": 2, "cache.example.com:11211": 3})
+	checkNum(len(k.c.sortedHashes), 88+88+180+272, t)
+	checkPlacement(k.c, map[string]string{
+		"foo":        "cache.example.com:11211",
+		"bar":        "cache.example.com:11211",
+		"baz":        "cache.example.com:11211",
+		"qux":        "10.0.1.1:11211",
+		"user:1001":  "10.0.1.1:11211",
+		"session:42": "cache.example.com:11211",
+		"a":          "10.0.1.3:11212",
+		"memcached":  "10.0.1.3:11212",
+	}, t)
+}
+
+func TestKetamaRecorded(t *testing.T) {
+	// testdata/ketama_memd_4node.json is memd_4node.exp.json from the test
+	// data of gocbcore v7.1.18 (Apache 2.0), the Couchbase Go client: the
+	// MD5 hash and server of 1024 keys in the ketama continuum of a
+	// memcached bucket, 160 points for each of these four servers, indexed
+	// in sorted order.
+	servers := []string{"10.0.0.195:12000", "localhost:12002", "localhost:12004", "localhost:12006"}
+	k := NewKetamaRing()
+	for _, s := range servers {
+		k.Add(s, nil)
+	}
+	data, err := os.ReadFile("testdata/ketama_memd_4node.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want map[string]struct {
+		Hash  uint32 `json:"hash"`
+		Index int    `json:"index"`
+	}
+	if err := json.Unmarshal(data, &want); err != nil {
+		t.Fatal(err)
+	}
+	checkNum(len(want), 1024, t)
+	for key, w := range want {
+		if h := uint32(k.c.HashKey(key)); h != w.Hash {
+			t.Fatalf("hash of %s: expected %d, got %d", key, w.Hash, h)
+		}
+		e, err := k.Get(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Key != servers[w.Index] {
+			t.Errorf("%s: expected %s, got %s", key, servers[w.Index], e.Key)
+		}
+	}
+}
+
+func TestKetamaRebuild(t *testing.T) {
+	k := NewKetamaRing()
+	for i := 1; i <= 4; i++ {
+		k.Add("10.0.1."+strconv.Itoa(i)+":11211", nil)
+	}
+	checkNum(len(k.c.sortedHashes), 4*KetamaPoints, t)
+	k.AddWeighted("10.0.1.4:11211", "heavy", 2)
+	checkNum(k.c.members["10.0.1.4:11211"].Replica, 256, t)
+	checkNum(k.c.members["10.0.1.1:11211"].Replica, 128, t)
+	checkNum(k.Len(), 4, t)
+
+	k.Remove("10.0.1.4:11211")
+	k.Remove("missing")
+	checkNum(k.Len(), 3, t)
+	checkNum(len(k.c.sortedHashes), 3*KetamaPoints, t)
+	if _, ok := k.Members()["10.0.1.4:11211"]; ok {
+		t.Errorf("expected 10.0.1.4:11211 to be removed")
+	}
+}
+
+func TestKetamaSchemeHasher64(t *testing.T) {
+	// ketama points are 32-bit, so 64-bit key hashes would all land past
+	// the last point
+	x := New(WithKeyScheme(Ketama), WithHasher64(FNV1a))
+	checkNum(x.bits(), 32, t)
+	for i := 0; i < 4; i++ {
+		x.Add("server"+strconv.Itoa(i), nil)
+	}
+	hits := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		e, err := x.Get(strconv.Itoa(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		hits[e.Key]++
+	}
+	checkNum(len(hits), 4, t)
+	checkNum(int(x.HashKey("foo")), int(FNV1a.Sum32([]byte("foo"))), t)
+}
+
+func TestLibmemcachedScheme(t *testing.T) {
+	// servers on the default port are labelled without it
+	a := Libmemcached.Points(nil, "host:11211", 8, nil)
+	b := Ketama.Points(nil, "host", 8, nil)
+	c := Libmemcached.Points(nil, "host:11212", 8, nil)
+	d := Ketama.Points(nil, "host:11212", 8, nil)
+	for i := range a {
+		if a[i] != b[i] || c[i] != d[i] {
+			t.Fatalf("unexpected point %d", i)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+package consistent
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultMaglevTableSize is the default size of a Maglev lookup table. It is
+// prime and large enough for about a hundred elements.
+const DefaultMaglevTableSize = 65537
+
+// ErrTableSize is returned by NewMaglev when the table size is not a prime.
+var ErrTableSize = errors.New("table size must be a prime")
+
+// Maglev implements Maglev hashing ("Maglev: A Fast and Reliable Software
+// Network Load Balancer", Eisenbud et al.). Every element fills slots of a
+// prime-sized lookup table following its own permutation, giving O(1)
+// lookups and a near-perfect balance with little disruption on changes.
+// The table should be much larger than the number of elements.
+//
+// Every change refills the whole table from the cached permutations, which
+// takes O(M log M) steps for a table of M slots (BenchmarkMaglevChange
+// measures Add and Remove at the default size). The refilled table only
+// depends on the set of elements, so processes holding the same elements
+// agree on where keys go whatever the order of their changes.
+//
+// Lookups read the latest published table without locking.
+type Maglev struct {
+	mu      sync.Mutex
+	hasher  Hasher64
+	size    uint64
+	members map[string]*maglevMember
+	table   atomic.Value // *maglevTable
+}
+
+// maglevMember caches the permutation of an element so that refilling the
+// table after a change does not hash every element again.
+type maglevMember struct {
+	elem   *Element
+	offset uint64
+	skip   uint64
+}
+
+// maglevTable is an immutable lookup table.
+type maglevTable struct {
+	slots   []*Element
+	members map[string]*Element
+}
+
+// NewMaglev creates a new Maglev object with a lookup table of tableSize
+// slots that hashes keys with h. tableSize must be a prime.
+func NewMaglev(h Hasher64, tableSize uint64) (*Maglev, error) {
+	if !isPrime(tableSize) {
+		return nil, ErrTableSize
+	}
+	m := &Maglev{
+		hasher:  h,
+		size:    tableSize,
+		members: make(map[string]*maglevMember),
+	}
+	m.table.Store(&maglevTable{members: map[string]*Element{}})
+	return m, nil
+}
+
+// Add inserts an element and refills the lookup table.
+func (m *Maglev) Add(key string, value interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.add(key, value)
+	m.populate()
+}
+
+// AddReplicas is the same as Add: every element of a Maglev table gets an
+// equal share of the slots.
+func (m *Maglev) AddReplicas(key string, value interface{}, replica int) {
+	m.Add(key, value)
+}
+
+// need m.mu.Lock() before calling
+func (m *Maglev) add(key string, value interface{}) {
+	elem := &Element{Key: key, Value: value, Replica: 1, Weight: 1}
+	if mm, ok := m.members[key]; ok {
+		mm.elem = elem
+		return
+	}
+	h := m.hasher.Sum64([]byte(key))
+	m.members[key] = &maglevMember{
+		elem:   elem,
+		offset: h % m.size,
+		skip:   murmurFmix64(h)%(m.size-1) + 1,
+	}
+}
+
+// Remove removes an element and refills the lookup table.
+func (m *Maglev) Remove(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.members[key]; !ok {
+		return
+	}
+	delete(m.members, key)
+	m.populate()
+}
+
+// Set sets all the elements and fills the lookup table once.
+func (m *Maglev) Set(kvs map[string]interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key := range m.members {
+		if _, ok := kvs[key]; !ok {
+			delete(m.members, key)
+		}
+	}
+	for k, v := range kvs {
+		m.add(k, v)
+	}
+	m.populate()
+}
+
+// populate fills a new lookup table and publishes it. Elements take turns in
+// key order, each claiming the next free slot of its permutation, so the
+// table only depends on the set of elements.
+// need m.mu.Lock() before calling
+func (m *Maglev) populate() {
+	members := make([]*maglevMember, 0, len(m.members))
+	t := &maglevTable{members: make(map[string]*Element, len(m.members))}
+	for k, mm := range m.members {
+		members = append(members, mm)
+		t.members[k] = mm.elem
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].elem.Key < members[j].elem.Key
+	})
+
+	if len(members) > 0 {
+		t.slots = make([]*Element, m.size)
+		// next[i] is the next slot in the permutation of members[i], stepped
+		// by skip without dividing; the bitmap of taken slots stays in cache
+		// unlike the table itself
+		next := make([]uint64, len(members))
+		for i, mm := range members {
+			next[i] = mm.offset
+		}
+		taken := make([]uint64, (m.size+63)/64)
+		for filled := uint64(0); filled < m.size; {
+			for i, mm := range members {
+				c := next[i]
+				for taken[c/64]&(1<<(c%64)) != 0 {
+					if c += mm.skip; c >= m.size {
+						c -= m.size
+					}
+				}
+				taken[c/64] |= 1 << (c % 64)
+				t.slots[c] = mm.elem
+				if c += mm.skip; c >= m.size {
+					c -= m.size
+				}
+				next[i] = c
+				filled++
+				if filled == m.size {
+					break
+				}
+			}
+		}
+	}
+	m.table.Store(t)
+}
+
+func (m *Maglev) load() *maglevTable {
+	return m.table.Load().(*maglevTable)
+}
+
+// Members return all members.
+func (m *Maglev) Members() map[string]interface{} {
+	t := m.load()
+	members := make(map[string]interface{}, len(t.members))
+	for k, v := range t.members {
+		members[k] = v
+	}
+	return members
+}
+
+// Len returns the number of members.
+func (m *Maglev) Len() int {
+	return len(m.load().members)
+}
+
+// Get returns the element owning the slot raw hashes to.
+func (m *Maglev) Get(raw string) (*Element, error) {
+	t := m.load()
+	if len(t.slots) == 0 {
+		return nil, ErrEmptyCircle
+	}
+	return t.slots[m.hasher.Sum64([]byte(raw))%m.size], nil
+}
+
+// GetTwo returns the two closest distinct elements to the slot raw hashes to.
+func (m *Maglev) GetTwo(raw string) (*Element, *Element, error) {
+	return getTwo(m.GetN, raw)
+}
+
+// GetN returns the N closest distinct elements to the slot raw hashes to,
+// walking the table forwards.
+func (m *Maglev) GetN(raw string, n int) ([]*Element, error) {
+	t := m.load()
+	if len(t.slots) == 0 {
+		return nil, ErrEmptyCircle
+	}
+	if len(t.members) < n {
+		n = len(t.members)
+	}
+	if n <= 0 {
+		return []*Element{}, nil
+	}
+	start := m.hasher.Sum64([]byte(raw)) % m.size
+	res := make([]*Element, 0, n)
+	res = append(res, t.slots[start])
+	for i := (start + 1) % m.size; i != start && len(res) < n; i = (i + 1) % m.size {
+		if !sliceContainsMember(res, t.slots[i]) {
+			res = append(res, t.slots[i])
+		}
+	}
+	return res, nil
+}
+
+func isPrime(n uint64) bool {
+	if n < 2 {
+		return false
+	}
+	for d := uint64(2); d*d <= n; d++ {
+		if n%d == 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+package consistent
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestNewMaglevTableSize(t *testing.T) {
+	for _, size := range []uint64{0, 1, 4, 65536} {
+		if _, err := NewMaglev(FNV1a, size); err != ErrTableSize {
+			t.Errorf("%d: expected table size error", size)
+		}
+	}
+	if _, err := NewMaglev(FNV1a, 13); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMaglevGetEmpty(t *testing.T) {
+	x, _ := NewMaglev(FNV1a, DefaultMaglevTableSize)
+	if _, err := x.Get("abcdefg"); err != ErrEmptyCircle {
+		t.Errorf("expected empty circle error")
+	}
+	x.Add("abcdefg", "value1")
+	x.Remove("abcdefg")
+	if _, err := x.GetN("abcdefg", 2); err != ErrEmptyCircle {
+		t.Errorf("expected empty circle error")
+	}
+}
+
+func TestMaglevBalanceAndDisruption(t *testing.T) {
+	x, _ := NewMaglev(XXHash, 1009)
+	for i := 0; i < 10; i++ {
+		x.Add("member"+strconv.Itoa(i), i)
+	}
+	checkNum(x.Len(), 10, t)
+	counts := make(map[string]int)
+	for _, e := range x.load().slots {
+		counts[e.Key]++
+	}
+	for key, n := range counts {
+		if n < 100 || n > 101 {
+			t.Errorf("%s owns %d slots, expected 100 or 101", key, n)
+		}
+	}
+
+	before := append([]*Element(nil), x.load().slots...)
+	x.Remove("member3")
+	moved := 0
+	for i, e := range x.load().slots {
+		if e.Key == "member3" {
+			t.Fatalf("slot %d still owned by removed element", i)
+		}
+		if before[i].Key != "member3" && before[i] != e {
+			moved++
+		}
+	}
+	if moved > len(before)/10 {
+		t.Errorf("removing one element disrupted %d other slots", moved)
+	}
+}
+
+func TestMaglevOrderIndependent(t *testing.T) {
+	x, _ := NewMaglev(FNV1a, 251)
+	y, _ := NewMaglev(FNV1a, 251)
+	x.Add("abcdefg", "value1")
+	x.Add("hijklmn", "value2")
+	x.Add("opqrstu", "value3")
+	y.Set(map[string]interface{}{"opqrstu": "value3", "hijklmn": "value2", "abcdefg": "value1"})
+	for i, e := range x.load().slots {
+		if y.load().slots[i].Key != e.Key {
+			t.Fatalf("slot %d differs", i)
+		}
+	}
+	a, b, err := x.GetTwo("abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	members, err := x.GetN("abc", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkNum(len(members), 3, t)
+	if a == b || members[0] != a || members[1] != b {
+		t.Errorf("GetTwo and GetN disagree")
+	}
+	if _, ok := x.Members()["hijklmn"]; !ok {
+		t.Errorf("expected hijklmn to be a member")
+	}
+}
+
+// benchmarkMaglevChange measures adding and removing an element of a table
+// of the default size holding n elements; every change refills the table.
+func benchmarkMaglevChange(b *testing.B, n int) {
+	m, err := NewMaglev(XXHash, DefaultMaglevTableSize)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		m.Add("member"+strconv.Itoa(i), i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Add("extra", nil)
+		m.Remove("extra")
+	}
+}
+
+func BenchmarkMaglevChange10(b *testing.B)  { benchmarkMaglevChange(b, 10) }
+func BenchmarkMaglevChange100(b *testing.B) { benchmarkMaglevChange(b, 100) }
@@ -0,0 +1,62 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+package consistent
+
+import (
+	"context"
+)
+
+// Migration is the placement of a key before and after a membership change.
+type Migration struct {
+	Key string
+	// Old and New are the preference lists of the key, as returned by GetN.
+	// They are nil when the corresponding circle is empty.
+	Old []*Element
+	New []*Element
+}
+
+// Moved reports whether the preference list of the key changed.
+func (m Migration) Moved() bool {
+	return !sameKeys(m.Old, m.New)
+}
+
+// Migrate classifies every key received from keys against the old and new
+// snapshots, using preference lists of length n (n = 1 compares Get). Results
+// are streamed in input order on the returned channel, which is closed once
+// keys is closed or ctx is done.
+//
+// To plan a change without applying it, take the new snapshot from a Clone:
+//
+//	next := c.Clone()
+//	next.Add("keyD", "valueD")
+//	for m := range consistent.Migrate(ctx, c.Snapshot(), next.Snapshot(), keys, 1) {
+//		...
+//	}
+func Migrate(ctx context.Context, old, new Snapshot, keys <-chan string, n int) <-chan Migration {
+	out := make(chan Migration)
+	go func() {
+		defer close(out)
+		for {
+			var key string
+			var ok bool
+			select {
+			case key, ok = <-keys:
+				if !ok {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+			before, _ := old.GetN(key, n)
+			after, _ := new.GetN(key, n)
+			select {
+			case out <- Migration{Key: key, Old: before, New: after}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,93 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+package consistent
+
+import (
+	"context"
+	"strconv"
+	"testing"
+)
+
+func feed(keys []string) <-chan string {
+	ch := make(chan string)
+	go func() {
+		for _, k := range keys {
+			ch <- k
+		}
+		close(ch)
+	}()
+	return ch
+}
+
+func TestClone(t *testing.T) {
+	x := New(WithHasher(FNV1a))
+	x.Add("abcdefg", "value1")
+	x.Add("hijklmn", "value2")
+	y := x.Clone()
+	y.Add("opqrstu", "value3")
+	y.Remove("abcdefg")
+	checkNum(len(x.Members()), 2, t)
+	checkNum(len(x.circle), 40, t)
+	checkNum(len(y.Members()), 2, t)
+	checkNum(int(y.Snapshot().Version()), 4, t)
+	if y.hashKey("abc") != x.hashKey("abc") {
+		t.Errorf("expected clone to keep the hasher")
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	x := New()
+	x.Add("abcdefg", "value1")
+	x.Add("hijklmn", "value2")
+	x.Add("opqrstu", "value3")
+	next := x.Clone()
+	next.Remove("hijklmn")
+
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	i := 0
+	moved := 0
+	for m := range Migrate(context.Background(), x.Snapshot(), next.Snapshot(), feed(keys), 1) {
+		if m.Key != keys[i] {
+			t.Fatalf("got %q, expected %q", m.Key, keys[i])
+		}
+		i++
+		old, _ := x.Get(m.Key)
+		new, _ := next.Get(m.Key)
+		if m.Old[0] != old || m.New[0] != new {
+			t.Errorf("%s: wrong placement", m.Key)
+		}
+		if m.Moved() {
+			moved++
+			if m.Old[0].Key != "hijklmn" {
+				t.Errorf("%s: moved away from %q", m.Key, m.Old[0].Key)
+			}
+		}
+	}
+	checkNum(i, len(keys), t)
+	if moved == 0 {
+		t.Errorf("expected some keys to move")
+	}
+	checkNum(len(x.Members()), 3, t)
+}
+
+func TestMigrateCancel(t *testing.T) {
+	x := New()
+	x.Add("abcdefg", "value1")
+	ctx, cancel := context.WithCancel(context.Background())
+	keys := make(chan string)
+	out := Migrate(ctx, x.Snapshot(), New().Snapshot(), keys, 2)
+	keys <- "abc"
+	m := <-out
+	if len(m.Old) != 1 || m.New != nil || !m.Moved() {
+		t.Errorf("unexpected migration %+v", m)
+	}
+	cancel()
+	if _, ok := <-out; ok {
+		t.Errorf("expected channel to be closed")
+	}
+}
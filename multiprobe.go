@@ -0,0 +1,189 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+package consistent
+
+import (
+	"sort"
+	"sync"
+)
+
+// DefaultProbeNumber is the default number of probes per key, which keeps
+// the peak-to-average load ratio around 1.1; it stays below 1.25 with 10
+// to 100 elements.
+const DefaultProbeNumber = 21
+
+// MultiProbe implements multi-probe consistent hashing ("Multi-Probe
+// Consistent Hashing" by Appleton and O'Reilly). Each element is a single
+// point on a 64-bit circle and each key is hashed Probes times; the key goes
+// to the element whose point is closest after any of the probes. This gives
+// the balance of many virtual nodes with one point per element.
+type MultiProbe struct {
+	mu      sync.RWMutex
+	hasher  Hasher64
+	probes  int
+	points  uints      // sorted points on the circle
+	owners  []*Element // owners[i] owns points[i]
+	members map[string]*Element
+}
+
+// NewMultiProbe creates a new MultiProbe object that hashes with h and
+// probes every key probes times. probes < 1 selects DefaultProbeNumber.
+func NewMultiProbe(h Hasher64, probes int) *MultiProbe {
+	if probes < 1 {
+		probes = DefaultProbeNumber
+	}
+	return &MultiProbe{
+		hasher:  h,
+		probes:  probes,
+		members: make(map[string]*Element),
+	}
+}
+
+// Add inserts an element.
+func (m *MultiProbe) Add(key string, value interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.add(key, value)
+}
+
+// AddReplicas is the same as Add: every element is a single point.
+func (m *MultiProbe) AddReplicas(key string, value interface{}, replica int) {
+	m.Add(key, value)
+}
+
+// need m.mu.Lock() before calling
+func (m *MultiProbe) add(key string, value interface{}) {
+	elem := &Element{Key: key, Value: value, Replica: 1, Weight: 1}
+	if _, ok := m.members[key]; ok {
+		m.members[key] = elem
+		for i, e := range m.owners {
+			if e.Key == key {
+				m.owners[i] = elem
+			}
+		}
+		return
+	}
+	m.members[key] = elem
+	// mixing spreads keys that differ in few bits, which weak hashers such
+	// as FNV-1a map to nearby points
+	p := murmurFmix64(m.hasher.Sum64([]byte(key)))
+	// points colliding with an existing one are ordered by key so that the
+	// smallest key wins regardless of insertion order
+	i := sort.Search(len(m.points), func(x int) bool {
+		return m.points[x] > p || (m.points[x] == p && m.owners[x].Key > key)
+	})
+	m.points = append(m.points, 0)
+	m.owners = append(m.owners, nil)
+	copy(m.points[i+1:], m.points[i:])
+	copy(m.owners[i+1:], m.owners[i:])
+	m.points[i], m.owners[i] = p, elem
+}
+
+// Remove removes an element.
+func (m *MultiProbe) Remove(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.members[key]; !ok {
+		return
+	}
+	delete(m.members, key)
+	for i, e := range m.owners {
+		if e.Key == key {
+			m.points = append(m.points[:i], m.points[i+1:]...)
+			m.owners = append(m.owners[:i], m.owners[i+1:]...)
+			break
+		}
+	}
+}
+
+// Set sets all the elements.
+func (m *MultiProbe) Set(kvs map[string]interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.points = make(uints, 0, len(kvs))
+	m.owners = make([]*Element, 0, len(kvs))
+	m.members = make(map[string]*Element, len(kvs))
+	for k, v := range kvs {
+		m.add(k, v)
+	}
+}
+
+// Members return all members.
+func (m *MultiProbe) Members() map[string]interface{} {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	members := make(map[string]interface{}, len(m.members))
+	for k, v := range m.members {
+		members[k] = v
+	}
+	return members
+}
+
+// Len returns the number of members.
+func (m *MultiProbe) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.members)
+}
+
+// Get returns the element closest after any of the probes of raw.
+func (m *MultiProbe) Get(raw string) (*Element, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(m.points) == 0 {
+		return nil, ErrEmptyCircle
+	}
+	return m.owners[m.nearest(raw)], nil
+}
+
+// GetTwo returns the two closest distinct elements to raw.
+func (m *MultiProbe) GetTwo(raw string) (*Element, *Element, error) {
+	return getTwo(m.GetN, raw)
+}
+
+// GetN returns the element chosen by Get followed by the next N-1 elements
+// clockwise from its point.
+func (m *MultiProbe) GetN(raw string, n int) ([]*Element, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(m.points) == 0 {
+		return nil, ErrEmptyCircle
+	}
+	if len(m.points) < n {
+		n = len(m.points)
+	}
+	if n < 0 {
+		n = 0
+	}
+	start := m.nearest(raw)
+	res := make([]*Element, n)
+	for i := range res {
+		res[i] = m.owners[(start+i)%len(m.owners)]
+	}
+	return res, nil
+}
+
+// nearest returns the index of the point with the smallest distance after
+// any probe of raw.
+// need m.mu.RLock() before calling
+func (m *MultiProbe) nearest(raw string) int {
+	h1 := murmurFmix64(m.hasher.Sum64([]byte(raw)))
+	h2 := murmurFmix64(h1) | 1
+	best, bestDist := 0, ^uint64(0)
+	for i := 0; i < m.probes; i++ {
+		p := h1 + uint64(i)*h2
+		j := sort.Search(len(m.points), func(x int) bool {
+			return m.points[x] > p
+		})
+		if j >= len(m.points) {
+			j = 0
+		}
+		// unsigned subtraction wraps around the circle
+		if d := m.points[j] - p; d < bestDist {
+			best, bestDist = j, d
+		}
+	}
+	return best
+}
@@ -0,0 +1,119 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+package consistent
+
+import (
+	"sort"
+	"strconv"
+	"testing"
+)
+
+func TestMultiProbeGetEmpty(t *testing.T) {
+	x := NewMultiProbe(FNV1a, 0)
+	checkNum(x.probes, DefaultProbeNumber, t)
+	if _, err := x.Get("abcdefg"); err != ErrEmptyCircle {
+		t.Errorf("expected empty circle error")
+	}
+	if _, _, err := x.GetTwo("abcdefg"); err != ErrEmptyCircle {
+		t.Errorf("expected empty circle error")
+	}
+}
+
+func TestMultiProbeBalance(t *testing.T) {
+	for _, h := range []Hasher64{FNV1a, XXHash, Murmur3} {
+		x := NewMultiProbe(h, 0)
+		for i := 0; i < 100; i++ {
+			x.Add("member"+strconv.Itoa(i), i)
+		}
+		counts := make(map[string]int)
+		for i := 0; i < 500000; i++ {
+			e, err := x.Get(strconv.Itoa(i))
+			if err != nil {
+				t.Fatal(err)
+			}
+			counts[e.Key]++
+		}
+		for key, n := range counts {
+			if n > 6250 {
+				t.Errorf("%T: %s: peak load %d, average 5000", h, key, n)
+			}
+		}
+	}
+}
+
+func TestMultiProbeAddRemove(t *testing.T) {
+	x := NewMultiProbe(XXHash, DefaultProbeNumber)
+	for i := 0; i < 10; i++ {
+		x.Add("member"+strconv.Itoa(i), i)
+	}
+	checkNum(len(x.points), 10, t)
+	if !sort.IsSorted(x.points) {
+		t.Errorf("expected points to be sorted")
+	}
+	before := make(map[string]string)
+	counts := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		e, err := x.Get(strconv.Itoa(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		before[strconv.Itoa(i)] = e.Key
+		counts[e.Key]++
+	}
+	// multi-probe bounds the peak load; an element right behind another one
+	// may still get very little
+	for key, n := range counts {
+		if n > 1250 {
+			t.Errorf("%s: peak load %d", key, n)
+		}
+	}
+
+	x.Remove("member4")
+	checkNum(x.Len(), 9, t)
+	checkNum(len(x.points), 9, t)
+	for k, old := range before {
+		e, _ := x.Get(k)
+		if old != "member4" && e.Key != old {
+			t.Errorf("%s moved from %q to %q", k, old, e.Key)
+		}
+	}
+	members, err := x.GetN("abc", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b, _ := x.GetTwo("abc")
+	if members[0] != a || members[1] != b || a == b {
+		t.Errorf("GetTwo and GetN disagree")
+	}
+}
+
+func TestMultiProbeCollision(t *testing.T) {
+	h := hasher64Func(func(data []byte) uint64 { return 42 })
+	x := NewMultiProbe(h, 3)
+	x.Add("b", "value-b")
+	x.Add("a", "value-a")
+	y := NewMultiProbe(h, 3)
+	y.Set(map[string]interface{}{"a": "value-a", "b": "value-b"})
+	for _, r := range []*MultiProbe{x, y} {
+		e, err := r.Get("abc")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Key != "a" {
+			t.Errorf("expected a to win the collision, got %q", e.Key)
+		}
+	}
+	x.Add("a", "value-a2")
+	checkNum(x.Len(), 2, t)
+	if e, _ := x.Get("abc"); e.Value != "value-a2" {
+		t.Errorf("expected value to be updated")
+	}
+}
+
+// hasher64Func adapts a 64-bit function to the Hasher64 interface for tests.
+type hasher64Func func(data []byte) uint64
+
+func (f hasher64Func) Sum32(data []byte) uint32 { return uint32(f(data)) }
+func (f hasher64Func) Sum64(data []byte) uint64 { return f(data) }
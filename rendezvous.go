@@ -0,0 +1,183 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+package consistent
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// Rendezvous implements weighted rendezvous, or highest random weight,
+// hashing. Every lookup scores all elements against the key and picks the
+// highest scores, so it needs no virtual nodes and moves only the keys of
+// the element that changed, at the cost of O(N) lookups.
+type Rendezvous struct {
+	mu       sync.RWMutex
+	hasher   Hasher64
+	elements []*Element
+	hashes   []uint64 // hashes[i] is the hash of elements[i].Key
+	index    map[string]int
+}
+
+// NewRendezvous creates a new Rendezvous object that hashes keys with h.
+func NewRendezvous(h Hasher64) *Rendezvous {
+	return &Rendezvous{
+		hasher: h,
+		index:  make(map[string]int),
+	}
+}
+
+// Add inserts an element with weight 1.
+func (r *Rendezvous) Add(key string, value interface{}) {
+	r.AddWeighted(key, value, 1)
+}
+
+// AddReplicas inserts an element whose weight is replica relative to
+// DefaultReplicaNumber, matching the share it would get in Consistent.
+func (r *Rendezvous) AddReplicas(key string, value interface{}, replica int) {
+	r.AddWeighted(key, value, float64(replica)/DefaultReplicaNumber)
+}
+
+// AddWeighted inserts an element that receives a share of the keys
+// proportional to weight. Elements with a weight <= 0 receive no keys: Get
+// and GetN leave them out and return ErrEmptyCircle when no element has a
+// positive weight.
+func (r *Rendezvous) AddWeighted(key string, value interface{}, weight float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.add(key, value, weight)
+}
+
+// need r.mu.Lock() before calling
+func (r *Rendezvous) add(key string, value interface{}, weight float64) {
+	elem := &Element{Key: key, Value: value, Replica: 1, Weight: weight}
+	if i, ok := r.index[key]; ok {
+		r.elements[i] = elem
+		return
+	}
+	r.index[key] = len(r.elements)
+	r.elements = append(r.elements, elem)
+	r.hashes = append(r.hashes, r.hasher.Sum64([]byte(key)))
+}
+
+// Remove removes an element.
+func (r *Rendezvous) Remove(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i, ok := r.index[key]
+	if !ok {
+		return
+	}
+	last := len(r.elements) - 1
+	r.elements[i], r.hashes[i] = r.elements[last], r.hashes[last]
+	r.index[r.elements[i].Key] = i
+	r.elements[last] = nil
+	r.elements, r.hashes = r.elements[:last], r.hashes[:last]
+	delete(r.index, key)
+}
+
+// Set sets all the elements with weight 1.
+func (r *Rendezvous) Set(kvs map[string]interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.elements = make([]*Element, 0, len(kvs))
+	r.hashes = make([]uint64, 0, len(kvs))
+	r.index = make(map[string]int, len(kvs))
+	for k, v := range kvs {
+		r.add(k, v, 1)
+	}
+}
+
+// Members return all members.
+func (r *Rendezvous) Members() map[string]interface{} {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	members := make(map[string]interface{}, len(r.elements))
+	for _, e := range r.elements {
+		members[e.Key] = e
+	}
+	return members
+}
+
+// Len returns the number of members.
+func (r *Rendezvous) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.elements)
+}
+
+// Get returns the element with the highest score for raw.
+func (r *Rendezvous) Get(raw string) (*Element, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	h := r.hasher.Sum64([]byte(raw))
+	best, bestScore := -1, math.Inf(-1)
+	for i := range r.elements {
+		if r.elements[i].Weight <= 0 {
+			continue
+		}
+		if s := r.score(i, h); best < 0 || s > bestScore || (s == bestScore && r.elements[i].Key < r.elements[best].Key) {
+			best, bestScore = i, s
+		}
+	}
+	if best < 0 {
+		return nil, ErrEmptyCircle
+	}
+	return r.elements[best], nil
+}
+
+// GetTwo returns the two elements with the highest scores for raw.
+func (r *Rendezvous) GetTwo(raw string) (*Element, *Element, error) {
+	return getTwo(r.GetN, raw)
+}
+
+// GetN returns the N elements with the highest scores for raw, best first.
+func (r *Rendezvous) GetN(raw string, n int) ([]*Element, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	h := r.hasher.Sum64([]byte(raw))
+	scored := make([]scoredElement, 0, len(r.elements))
+	for i, e := range r.elements {
+		if e.Weight > 0 {
+			scored = append(scored, scoredElement{e, r.score(i, h)})
+		}
+	}
+	if len(scored) == 0 {
+		return nil, ErrEmptyCircle
+	}
+	if len(scored) < n {
+		n = len(scored)
+	}
+	if n < 0 {
+		n = 0
+	}
+	sort.Slice(scored, func(i, j int) bool {
+		if scored[i].score != scored[j].score {
+			return scored[i].score > scored[j].score
+		}
+		return scored[i].elem.Key < scored[j].elem.Key
+	})
+	res := make([]*Element, n)
+	for i := range res {
+		res[i] = scored[i].elem
+	}
+	return res, nil
+}
+
+type scoredElement struct {
+	elem  *Element
+	score float64
+}
+
+// score returns the weighted score of element i for a key hashing to h,
+// -weight/ln(u) with u uniform in (0, 1), so that the probability of an
+// element winning is proportional to its weight, which must be positive.
+// need r.mu.RLock() before calling
+func (r *Rendezvous) score(i int, h uint64) float64 {
+	w := r.elements[i].Weight
+	u := (float64(murmurFmix64(h^r.hashes[i])>>11) + 0.5) / (1 << 53)
+	return -w / math.Log(u)
+}
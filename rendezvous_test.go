@@ -0,0 +1,105 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+package consistent
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestRendezvousGetEmpty(t *testing.T) {
+	x := NewRendezvous(FNV1a)
+	if _, err := x.Get("abcdefg"); err != ErrEmptyCircle {
+		t.Errorf("expected empty circle error")
+	}
+	if _, err := x.GetN("abcdefg", 3); err != ErrEmptyCircle {
+		t.Errorf("expected empty circle error")
+	}
+}
+
+func TestRendezvousMinimalMovement(t *testing.T) {
+	x := NewRendezvous(XXHash)
+	x.Set(map[string]interface{}{"abcdefg": 1, "hijklmn": 2, "opqrstu": 3})
+	checkNum(x.Len(), 3, t)
+	before := make(map[string][]*Element)
+	for i := 0; i < 1000; i++ {
+		k := strconv.Itoa(i)
+		members, err := x.GetN(k, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkNum(len(members), 3, t)
+		if e, _ := x.Get(k); e != members[0] {
+			t.Fatalf("Get and GetN disagree for %s", k)
+		}
+		before[k] = members
+	}
+	x.Remove("hijklmn")
+	for k, old := range before {
+		a, b, err := x.GetTwo(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the remaining elements keep their relative order
+		var want []*Element
+		for _, e := range old {
+			if e.Key != "hijklmn" {
+				want = append(want, e)
+			}
+		}
+		if a != want[0] || b != want[1] {
+			t.Errorf("%s: got %q %q", k, a.Key, b.Key)
+		}
+	}
+}
+
+func TestRendezvousNoPositiveWeight(t *testing.T) {
+	x := NewRendezvous(FNV1a)
+	x.AddWeighted("abcdefg", nil, 0)
+	x.AddWeighted("hijklmn", nil, -1)
+	checkNum(x.Len(), 2, t)
+	if _, err := x.Get("foo"); err != ErrEmptyCircle {
+		t.Errorf("expected empty circle error, got %v", err)
+	}
+	if _, err := x.GetN("foo", 2); err != ErrEmptyCircle {
+		t.Errorf("expected empty circle error, got %v", err)
+	}
+	if _, _, err := x.GetTwo("foo"); err != ErrEmptyCircle {
+		t.Errorf("expected empty circle error, got %v", err)
+	}
+}
+
+func TestRendezvousWeighted(t *testing.T) {
+	x := NewRendezvous(FNV1a)
+	x.AddWeighted("light", "value-light", 1)
+	x.AddWeighted("heavy", "value-heavy", 3)
+	x.AddWeighted("off", "value-off", 0)
+	x.AddReplicas("half", "value-half", DefaultReplicaNumber/2)
+	hits := make(map[string]int)
+	for i := 0; i < 40000; i++ {
+		e, err := x.Get(strconv.Itoa(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		hits[e.Key]++
+	}
+	checkNum(hits["off"], 0, t)
+	all, err := x.GetN("abcdefg", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkNum(len(all), 3, t)
+	for _, e := range all {
+		if e.Key == "off" {
+			t.Errorf("expected off to be left out of GetN")
+		}
+	}
+	if ratio := float64(hits["heavy"]) / float64(hits["light"]); ratio < 2.7 || ratio > 3.3 {
+		t.Errorf("expected heavy to get about 3x the keys of light, got %.2f", ratio)
+	}
+	if ratio := float64(hits["light"]) / float64(hits["half"]); ratio < 1.8 || ratio > 2.2 {
+		t.Errorf("expected light to get about 2x the keys of half, got %.2f", ratio)
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+package consistent
+
+// Ring is the interface shared by every hashing implementation of this
+// package, so that callers can swap algorithms or substitute a mock.
+//
+// Lookups return ErrEmptyCircle when the ring has no members. GetTwo and
+// GetN return distinct elements, the first of which is the one Get returns.
+type Ring interface {
+	Add(key string, value interface{})
+	AddReplicas(key string, value interface{}, replica int)
+	Remove(key string)
+	Set(kvs map[string]interface{})
+	Get(raw string) (*Element, error)
+	GetTwo(raw string) (*Element, *Element, error)
+	GetN(raw string, n int) ([]*Element, error)
+	Members() map[string]interface{}
+	Len() int
+}
+
+var (
+	_ Ring = (*Consistent)(nil)
+	_ Ring = (*Jump)(nil)
+	_ Ring = (*Rendezvous)(nil)
+	_ Ring = (*Maglev)(nil)
+	_ Ring = (*MultiProbe)(nil)
+	_ Ring = (*Anchor)(nil)
+	_ Ring = (*KetamaRing)(nil)
+)
+
+// getTwo implements GetTwo on top of the GetN of a ring.
+func getTwo(getN func(raw string, n int) ([]*Element, error), raw string) (*Element, *Element, error) {
+	res, err := getN(raw, 2)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch len(res) {
+	case 0:
+		return nil, nil, ErrEmptyCircle
+	case 1:
+		return res[0], nil, nil
+	}
+	return res[0], res[1], nil
+}
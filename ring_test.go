@@ -0,0 +1,45 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+package consistent_test
+
+import (
+	"testing"
+
+	consistent "github.com/zhvala/goconsistent"
+	"github.com/zhvala/goconsistent/ringtest"
+)
+
+func TestRingConformance(t *testing.T) {
+	rings := []struct {
+		name    string
+		newRing func() consistent.Ring
+	}{
+		{"Consistent", func() consistent.Ring { return consistent.New() }},
+		{"Consistent64", func() consistent.Ring { return consistent.New64(consistent.XXHash) }},
+		{"Jump", func() consistent.Ring { return consistent.NewJump(consistent.FNV1a) }},
+		{"Rendezvous", func() consistent.Ring { return consistent.NewRendezvous(consistent.FNV1a) }},
+		{"Maglev", func() consistent.Ring {
+			m, err := consistent.NewMaglev(consistent.FNV1a, 1009)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return m
+		}},
+		{"MultiProbe", func() consistent.Ring { return consistent.NewMultiProbe(consistent.XXHash, 0) }},
+		{"Anchor", func() consistent.Ring {
+			a, err := consistent.NewAnchor(consistent.FNV1a, 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return a
+		}},
+		{"Ketama", func() consistent.Ring { return consistent.NewKetamaRing() }},
+	}
+	for _, r := range rings {
+		t.Run(r.name, func(t *testing.T) {
+			ringtest.Run(t, r.newRing)
+		})
+	}
+}
@@ -0,0 +1,289 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+// Package ringtest implements a conformance test suite for implementations
+// of consistent.Ring.
+package ringtest
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"testing/quick"
+
+	consistent "github.com/zhvala/goconsistent"
+)
+
+// Run runs the conformance suite against rings created by newRing. Every
+// call to newRing must return a new, empty ring.
+func Run(t *testing.T, newRing func() consistent.Ring) {
+	tests := []struct {
+		name string
+		f    func(*testing.T, func() consistent.Ring)
+	}{
+		{"Empty", testEmpty},
+		{"Len", testLen},
+		{"RemoveNonExisting", testRemoveNonExisting},
+		{"GetSingle", testGetSingle},
+		{"GetMultiple", testGetMultiple},
+		{"GetRemove", testGetRemove},
+		{"GetTwo", testGetTwo},
+		{"GetTwoOnlyOne", testGetTwoOnlyOne},
+		{"GetN", testGetN},
+		{"Set", testSet},
+		{"Concurrent", testConcurrent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.f(t, newRing)
+		})
+	}
+}
+
+var keys = []string{"abcdefg", "hijklmn", "opqrstu"}
+
+func fill(r consistent.Ring, keys ...string) {
+	for _, k := range keys {
+		r.Add(k, "value-"+k)
+	}
+}
+
+func isOneOf(e *consistent.Element, keys ...string) bool {
+	if e == nil {
+		return false
+	}
+	for _, k := range keys {
+		if e.Key == k {
+			return e.Value == "value-"+k
+		}
+	}
+	return false
+}
+
+func testEmpty(t *testing.T, newRing func() consistent.Ring) {
+	r := newRing()
+	if _, err := r.Get("asdfsadfsadf"); err != consistent.ErrEmptyCircle {
+		t.Errorf("Get: expected empty circle error, got %v", err)
+	}
+	if _, _, err := r.GetTwo("asdfsadfsadf"); err != consistent.ErrEmptyCircle {
+		t.Errorf("GetTwo: expected empty circle error, got %v", err)
+	}
+	if _, err := r.GetN("asdfsadfsadf", 3); err != consistent.ErrEmptyCircle {
+		t.Errorf("GetN: expected empty circle error, got %v", err)
+	}
+	if r.Len() != 0 || len(r.Members()) != 0 {
+		t.Errorf("expected no members")
+	}
+}
+
+func testLen(t *testing.T, newRing func() consistent.Ring) {
+	r := newRing()
+	fill(r, keys...)
+	r.AddReplicas("vwxyz", "value-vwxyz", 5)
+	r.Add("abcdefg", "value-abcdefg")
+	if r.Len() != 4 {
+		t.Errorf("expected 4 members, got %d", r.Len())
+	}
+	members := r.Members()
+	for _, k := range append(keys, "vwxyz") {
+		if _, ok := members[k]; !ok {
+			t.Errorf("expected %q in members", k)
+		}
+	}
+}
+
+func testRemoveNonExisting(t *testing.T, newRing func() consistent.Ring) {
+	r := newRing()
+	fill(r, "abcdefg")
+	r.Remove("abcdefghijk")
+	if r.Len() != 1 {
+		t.Errorf("expected 1 member, got %d", r.Len())
+	}
+	r.Remove("abcdefg")
+	if _, err := r.Get("abcdefg"); err != consistent.ErrEmptyCircle {
+		t.Errorf("expected empty circle error after removing the last member")
+	}
+}
+
+func testGetSingle(t *testing.T, newRing func() consistent.Ring) {
+	r := newRing()
+	fill(r, "abcdefg")
+	f := func(s string) bool {
+		y, err := r.Get(s)
+		return err == nil && isOneOf(y, "abcdefg")
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testGetMultiple(t *testing.T, newRing func() consistent.Ring) {
+	r := newRing()
+	fill(r, keys...)
+	seen := make(map[string]bool)
+	f := func(s string) bool {
+		y, err := r.Get(s)
+		if err != nil || !isOneOf(y, keys...) {
+			return false
+		}
+		seen[y.Key] = true
+		return true
+	}
+	if err := quick.Check(f, &quick.Config{MaxCount: 1000}); err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != len(keys) {
+		t.Errorf("expected keys to spread over all members, got %v", seen)
+	}
+}
+
+func testGetRemove(t *testing.T, newRing func() consistent.Ring) {
+	r := newRing()
+	fill(r, keys...)
+	before := make(map[string]string)
+	for i := 0; i < 1000; i++ {
+		y, _ := r.Get(strconv.Itoa(i))
+		before[strconv.Itoa(i)] = y.Key
+	}
+	r.Remove("opqrstu")
+	// keys of the remaining elements should stay put; some algorithms such
+	// as Maglev allow a small disruption
+	moved := 0
+	for k, old := range before {
+		y, err := r.Get(k)
+		if err != nil || !isOneOf(y, "abcdefg", "hijklmn") {
+			t.Fatalf("%s: unexpected element after remove", k)
+		}
+		if old != "opqrstu" && y.Key != old {
+			moved++
+		}
+	}
+	if moved > len(before)/20 {
+		t.Errorf("removing one element moved %d other keys", moved)
+	}
+}
+
+func testGetTwo(t *testing.T, newRing func() consistent.Ring) {
+	for _, members := range [][]string{keys, keys[:2]} {
+		r := newRing()
+		fill(r, members...)
+		f := func(s string) bool {
+			a, b, err := r.GetTwo(s)
+			if err != nil || a == b || !isOneOf(a, members...) || !isOneOf(b, members...) {
+				return false
+			}
+			first, _ := r.Get(s)
+			return first == a
+		}
+		if err := quick.Check(f, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func testGetTwoOnlyOne(t *testing.T, newRing func() consistent.Ring) {
+	r := newRing()
+	fill(r, "abcdefg")
+	a, b, err := r.GetTwo("99999999")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isOneOf(a, "abcdefg") || b != nil {
+		t.Errorf("expected abcdefg and nil")
+	}
+}
+
+func testGetN(t *testing.T, newRing func() consistent.Ring) {
+	r := newRing()
+	fill(r, keys...)
+	for _, n := range []int{0, 1, 2, 3, 5} {
+		want := n
+		if want > len(keys) {
+			want = len(keys)
+		}
+		f := func(s string) bool {
+			members, err := r.GetN(s, n)
+			if err != nil || len(members) != want {
+				return false
+			}
+			set := make(map[*consistent.Element]bool)
+			for _, m := range members {
+				if set[m] || !isOneOf(m, keys...) {
+					return false
+				}
+				set[m] = true
+			}
+			if n > 0 {
+				first, _ := r.Get(s)
+				return first == members[0]
+			}
+			return true
+		}
+		if err := quick.Check(f, nil); err != nil {
+			t.Fatalf("n = %d: %v", n, err)
+		}
+	}
+}
+
+func testSet(t *testing.T, newRing func() consistent.Ring) {
+	r := newRing()
+	fill(r, "abc", "def", "ghi")
+	r.Set(map[string]interface{}{"jkl": "value-jkl", "mno": "value-mno"})
+	if r.Len() != 2 {
+		t.Errorf("expected 2 elts, got %d", r.Len())
+	}
+	a, b, err := r.GetTwo("qwerqwerwqer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isOneOf(a, "jkl", "mno") || !isOneOf(b, "jkl", "mno") || a == b {
+		t.Errorf("expected jkl and mno")
+	}
+	r.Set(map[string]interface{}{"pqr": "value-pqr", "mno": "value-mno"})
+	if r.Len() != 2 {
+		t.Errorf("expected 2 elts, got %d", r.Len())
+	}
+	a, b, err = r.GetTwo("qwerqwerwqer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isOneOf(a, "pqr", "mno") || !isOneOf(b, "pqr", "mno") || a == b {
+		t.Errorf("expected pqr and mno")
+	}
+}
+
+func testConcurrent(t *testing.T, newRing func() consistent.Ring) {
+	r := newRing()
+	first := map[string]interface{}{"abc": "value-abc", "def": "value-def", "ghi": "value-ghi"}
+	second := map[string]interface{}{"pqr": "value-pqr", "stu": "value-stu"}
+	r.Set(first)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			r.Set(second)
+			r.Set(first)
+		}
+	}()
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				a, err := r.Get(strconv.Itoa(i))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if !isOneOf(a, "abc", "def", "ghi", "pqr", "stu") {
+					t.Errorf("unexpected element")
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,76 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+package consistent
+
+import (
+	"strconv"
+)
+
+// KeyScheme places the virtual nodes of an element on the circle. Together
+// with the hasher it decides where keys land, so rings meant to agree with
+// another library must use the same scheme and hasher as that library.
+type KeyScheme interface {
+	// Name identifies the scheme in fingerprints and encodings.
+	Name() string
+	// Points appends the points of the first n virtual nodes of key to dst.
+	// hash returns the point of a label with the hasher of the circle.
+	Points(dst []uint64, key string, n int, hash func(label string) uint64) []uint64
+	// Inclusive reports whether a key hashing exactly onto a point belongs
+	// to the owner of that point rather than to the owner of the next one.
+	Inclusive() bool
+}
+
+var (
+	// Stathat labels virtual node i of key "key" as strconv.Itoa(i) + key,
+	// like github.com/stathat/consistent. It is the default scheme.
+	Stathat KeyScheme = stathatScheme{}
+	// Groupcache uses the labels of Stathat but, like the consistenthash
+	// package of github.com/golang/groupcache, gives keys hashing onto a
+	// point to the owner of that point.
+	Groupcache KeyScheme = groupcacheScheme{}
+	// Ketama places the virtual nodes of key "key" four at a time, reading
+	// little-endian 32-bit points from the MD5 digest of key + "-" +
+	// strconv.Itoa(i), like libketama. The points do not depend on the
+	// hasher; use it with WithHasher(MD5) to hash keys like ketama clients.
+	// The circle stays 32-bit: with WithHasher64, keys are hashed with the
+	// Sum32 of the hasher.
+	Ketama KeyScheme = ketamaScheme{}
+)
+
+// WithKeyScheme sets the scheme placing virtual nodes on the circle.
+func WithKeyScheme(s KeyScheme) Option {
+	return func(c *Consistent) {
+		c.scheme = s
+	}
+}
+
+type stathatScheme struct{}
+
+func (stathatScheme) Name() string { return "stathat" }
+
+func (stathatScheme) Points(dst []uint64, key string, n int, hash func(string) uint64) []uint64 {
+	for i := 0; i < n; i++ {
+		dst = append(dst, hash(strconv.Itoa(i)+key))
+	}
+	return dst
+}
+
+func (stathatScheme) Inclusive() bool { return false }
+
+type groupcacheScheme struct{ stathatScheme }
+
+func (groupcacheScheme) Name() string { return "groupcache" }
+
+func (groupcacheScheme) Inclusive() bool { return true }
+
+// schemeByName returns the scheme of this package called name, or nil.
+func schemeByName(name string) KeyScheme {
+	for _, s := range []KeyScheme{Stathat, Groupcache, Ketama, Libmemcached} {
+		if s.Name() == name {
+			return s
+		}
+	}
+	return nil
+}
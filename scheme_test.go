@@ -0,0 +1,105 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+package consistent
+
+import (
+	"strconv"
+	"testing"
+)
+
+// atoiHasher hashes decimal labels to their value, like the tests of
+// groupcache's consistenthash package.
+var atoiHasher = HasherFunc(func(data []byte) uint32 {
+	i, _ := strconv.Atoi(string(data))
+	return uint32(i)
+})
+
+func checkPlacement(x *Consistent, golden map[string]string, t *testing.T) {
+	t.Helper()
+	for key, want := range golden {
+		e, err := x.Get(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Key != want {
+			t.Errorf("Get(%q) = %s, want %s", key, e.Key, want)
+		}
+	}
+}
+
+func TestGroupcacheScheme(t *testing.T) {
+	// golden placements of groupcache's TestHashing
+	x := New(WithHasher(atoiHasher), WithKeyScheme(Groupcache))
+	for _, k := range []string{"6", "4", "2"} {
+		x.AddReplicas(k, nil, 3)
+	}
+	checkPlacement(x, map[string]string{"2": "2", "11": "2", "23": "4", "27": "2"}, t)
+	x.AddReplicas("8", nil, 3)
+	checkPlacement(x, map[string]string{"2": "2", "11": "2", "23": "4", "27": "8"}, t)
+}
+
+func TestStathatScheme(t *testing.T) {
+	x := New(WithHasher(atoiHasher))
+	for _, k := range []string{"6", "4", "2"} {
+		x.AddReplicas(k, nil, 3)
+	}
+	// keys hashing onto a point belong to the next one
+	checkPlacement(x, map[string]string{"2": "4", "11": "2", "23": "4", "27": "2"}, t)
+
+	x = New(WithKeyScheme(Stathat))
+	x.Set(map[string]interface{}{"cacheA": nil, "cacheB": nil, "cacheC": nil})
+	checkPlacement(x, map[string]string{
+		"foo":        "cacheC",
+		"bar":        "cacheA",
+		"baz":        "cacheA",
+		"user:1001":  "cacheA",
+		"session:42": "cacheC",
+	}, t)
+}
+
+func TestKetamaScheme(t *testing.T) {
+	x := New(WithHasher(MD5), WithKeyScheme(Ketama))
+	for i := 1; i <= 4; i++ {
+		x.AddReplicas("10.0.1."+strconv.Itoa(i)+":11211", nil, 160)
+	}
+	checkNum(len(x.sortedHashes), 640, t)
+	checkPlacement(x, map[string]string{
+		"foo":        "10.0.1.2:11211",
+		"bar":        "10.0.1.4:11211",
+		"baz":        "10.0.1.2:11211",
+		"user:1001":  "10.0.1.4:11211",
+		"session:42": "10.0.1.1:11211",
+		"a":          "10.0.1.3:11211",
+	}, t)
+
+	x.Remove("10.0.1.3:11211")
+	checkNum(len(x.sortedHashes), 480, t)
+	x.AddReplicas("10.0.1.3:11211", nil, 6)
+	checkNum(len(x.sortedHashes), 486, t)
+}
+
+func TestKeySchemeFingerprint(t *testing.T) {
+	x := New()
+	y := New(WithKeyScheme(Groupcache))
+	x.Add("abcdefg", nil)
+	y.Add("abcdefg", nil)
+	if x.Fingerprint() == y.Fingerprint() {
+		t.Errorf("expected the key scheme to change the fingerprint")
+	}
+	data, err := y.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := x.UnmarshalBinary(data); err != ErrHasherMismatch {
+		t.Errorf("expected hasher mismatch, got %v", err)
+	}
+	var z Consistent
+	if err := z.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if z.Fingerprint() != y.Fingerprint() {
+		t.Errorf("expected the decoded circle to keep its key scheme")
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+package consistent
+
+// Snapshot is a read-only view of a Consistent object at a point in time.
+// All lookups on a Snapshot see the same members, however the Consistent
+// object it was taken from changes afterwards. Snapshots are cheap to take
+// and safe for concurrent use.
+type Snapshot struct {
+	c *Consistent
+	v *view
+}
+
+// Snapshot returns a view of the current members of the consistent hash.
+func (c *Consistent) Snapshot() Snapshot {
+	return Snapshot{c: c, v: c.load()}
+}
+
+// Version returns the version of the circle captured by the snapshot. It
+// increases by one for every change made to the Consistent object.
+func (s Snapshot) Version() uint64 {
+	return s.v.version
+}
+
+// Len returns the number of members in the snapshot.
+func (s Snapshot) Len() int {
+	return s.v.count
+}
+
+// Members return all members in the snapshot.
+func (s Snapshot) Members() map[string]interface{} {
+	members := make(map[string]interface{}, s.v.count)
+	for _, e := range s.v.elems {
+		if e != nil {
+			members[e.Key] = e
+		}
+	}
+	return members
+}
+
+// Get returns an element close to where name hashes to in the circle.
+func (s Snapshot) Get(raw string) (*Element, error) {
+	return s.v.get(s.c.hashKey(raw))
+}
+
+// GetTwo returns the two closest distinct elements to the name input in the circle.
+func (s Snapshot) GetTwo(name string) (*Element, *Element, error) {
+	return s.v.getTwo(s.c.hashKey(name))
+}
+
+// GetN returns the N closest distinct elements to the name input in the circle.
+func (s Snapshot) GetN(name string, n int) ([]*Element, error) {
+	return s.v.getN(s.c.hashKey(name), n)
+}
+
+// GetByHash returns the element closest to point h, as computed by
+// Consistent.HashKey.
+func (s Snapshot) GetByHash(h uint64) (*Element, error) {
+	return s.v.get(h)
+}
+
+// GetNByHash returns the N closest distinct elements to point h, as computed
+// by Consistent.HashKey.
+func (s Snapshot) GetNByHash(h uint64, n int) ([]*Element, error) {
+	return s.v.getN(h, n)
+}
@@ -0,0 +1,66 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+package consistent
+
+import (
+	"testing"
+)
+
+func TestSnapshotEmpty(t *testing.T) {
+	s := New().Snapshot()
+	checkNum(s.Len(), 0, t)
+	checkNum(int(s.Version()), 0, t)
+	if _, err := s.Get("abcdefg"); err != ErrEmptyCircle {
+		t.Errorf("expected empty circle error")
+	}
+	if _, _, err := s.GetTwo("abcdefg"); err != ErrEmptyCircle {
+		t.Errorf("expected empty circle error")
+	}
+	if _, err := s.GetN("abcdefg", 2); err != ErrEmptyCircle {
+		t.Errorf("expected empty circle error")
+	}
+}
+
+func TestSnapshotStable(t *testing.T) {
+	x := New()
+	x.Add("abcdefg", "value1")
+	x.Add("hijklmn", "value2")
+	x.Add("opqrstu", "value3")
+	s := x.Snapshot()
+	checkNum(int(s.Version()), 3, t)
+
+	x.Remove("hijklmn")
+	x.Add("vwxyz", "value4")
+	checkNum(int(x.Snapshot().Version()), 5, t)
+
+	checkNum(s.Len(), 3, t)
+	if _, ok := s.Members()["hijklmn"]; !ok {
+		t.Errorf("expected snapshot to keep hijklmn")
+	}
+	for i, v := range rtestsBefore {
+		result, err := s.Get(v.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Key != v.out {
+			t.Errorf("%d. got %q, expected %q", i, result.Key, v.out)
+		}
+	}
+	a, b, err := s.GetTwo("99999999")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Key != "abcdefg" || b.Key != "hijklmn" {
+		t.Errorf("got %q and %q, expected abcdefg and hijklmn", a.Key, b.Key)
+	}
+	members, err := s.GetN("9999999", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkNum(len(members), 3, t)
+	if members[0].Key != "opqrstu" || members[1].Key != "abcdefg" || members[2].Key != "hijklmn" {
+		t.Errorf("wrong members: %q %q %q", members[0].Key, members[1].Key, members[2].Key)
+	}
+}
@@ -0,0 +1,68 @@
+#!/usr/bin/env python3
+# Documents how the placements checked by TestKetamaGolden were derived;
+# the test does not run it. TestKetamaRecorded checks recorded client output
+# instead, in ketama_memd_4node.json.
+#
+# This follows update_continuum and dispatch_host in libmemcached/hosts.cc
+# and libmemcached/hash.cc of libmemcached 1.0.18, for a client with
+# MEMCACHED_BEHAVIOR_KETAMA_WEIGHTED and MEMCACHED_HASH_MD5 set. It is an
+# independent transcription of that code, not output of libmemcached.
+
+import bisect
+import hashlib
+import math
+import struct
+
+POINTS_PER_SERVER = 160  # MEMCACHED_POINTS_PER_SERVER_KETAMA
+POINTS_PER_HASH = 4
+DEFAULT_PORT = 11211
+
+SERVERS = [
+    ("10.0.1.1", 11211, 1),
+    ("10.0.1.2", 11211, 1),
+    ("10.0.1.3", 11212, 2),
+    ("cache.example.com", 11211, 3),
+]
+KEYS = ["foo", "bar", "baz", "qux", "user:1001", "session:42", "a", "memcached"]
+
+
+def f32(x):
+    return struct.unpack("f", struct.pack("f", x))[0]
+
+
+def md5_points(label):
+    digest = hashlib.md5(label.encode()).digest()
+    return struct.unpack("<4I", digest)
+
+
+def continuum():
+    total = sum(w for _, _, w in SERVERS)
+    points = []
+    for host, port, weight in SERVERS:
+        pct = f32(f32(weight) / f32(total))
+        n = int(math.floor(f32(pct * POINTS_PER_SERVER / 4 * len(SERVERS) + 0.0000000001))) * 4
+        name = "%s:%d" % (host, port)
+        for i in range(n // POINTS_PER_HASH):
+            if port == DEFAULT_PORT:
+                label = "%s-%d" % (host, i)
+            else:
+                label = "%s:%d-%d" % (host, port, i)
+            points.extend((p, name) for p in md5_points(label))
+    points.sort()
+    return points
+
+
+def main():
+    points = continuum()
+    values = [p for p, _ in points]
+    print("points:", len(points))
+    for key in KEYS:
+        h = md5_points(key)[0]
+        i = bisect.bisect_left(values, h)
+        if i == len(values):
+            i = 0
+        print("%s: %s" % (key, points[i][1]))
+
+
+if __name__ == "__main__":
+    main()
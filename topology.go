@@ -0,0 +1,132 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+package consistent
+
+// Topology labels the failure domains an element belongs to.
+type Topology struct {
+	Region string
+	Zone   string
+	Rack   string
+}
+
+// Level selects the failure domain GetNDistinct spreads elements over.
+type Level int
+
+const (
+	// LevelRegion places every element in a different region.
+	LevelRegion Level = iota + 1
+	// LevelZone places every element in a different zone.
+	LevelZone
+	// LevelRack places every element in a different rack.
+	LevelRack
+)
+
+// domain returns the failure domain of t at level. Zones and racks are
+// qualified by their parents, so "a" in two regions are distinct zones.
+// An element without a label at level is a domain of its own.
+func (t Topology) domain(level Level) (Topology, bool) {
+	switch level {
+	case LevelRegion:
+		return Topology{Region: t.Region}, t.Region != ""
+	case LevelZone:
+		return Topology{Region: t.Region, Zone: t.Zone}, t.Zone != ""
+	case LevelRack:
+		return t, t.Rack != ""
+	}
+	return Topology{}, false
+}
+
+// AddWithTopology inserts a element located at topo in the consistent hash.
+func (c *Consistent) AddWithTopology(key string, value interface{}, topo Topology) {
+	defer c.flush()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	old := c.members[key]
+	c.add(key, value, c.NumberOfReplicas, 1, false)
+	c.members[key].Topology = topo
+	c.publish()
+	c.emitChange(key, old)
+}
+
+// SetTopology changes the location of an existing element. It reports
+// whether the element exists. Labels survive re-adding the element with Add
+// or Set, and are dropped by Remove.
+func (c *Consistent) SetTopology(key string, topo Topology) bool {
+	defer c.flush()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.members[key]
+	if !ok {
+		return false
+	}
+	updated := *e
+	updated.Topology = topo
+	c.setMember(&updated)
+	c.publish()
+	c.emitChange(key, e)
+	return true
+}
+
+// GetNDistinct returns up to N distinct elements close to where name hashes
+// to in the circle, each from a different failure domain at level. When
+// there are fewer domains than N, the remaining slots are filled with the
+// closest elements not yet picked, so replicas still land on distinct
+// elements.
+func (c *Consistent) GetNDistinct(name string, n int, level Level) ([]*Element, error) {
+	return c.load().getNDistinct(c.hashKey(name), n, level)
+}
+
+// GetNDistinct returns up to N distinct elements from distinct failure
+// domains, see Consistent.GetNDistinct.
+func (s Snapshot) GetNDistinct(name string, n int, level Level) ([]*Element, error) {
+	return s.v.getNDistinct(s.c.hashKey(name), n, level)
+}
+
+func (v *view) getNDistinct(key uint64, n int, level Level) ([]*Element, error) {
+	if len(v.hashes) == 0 {
+		return nil, ErrEmptyCircle
+	}
+	if v.count < n {
+		n = v.count
+	}
+	if n <= 0 {
+		return []*Element{}, nil
+	}
+
+	var (
+		start   = v.search(key)
+		res     = make([]*Element, 0, n)
+		domains = make(map[Topology]bool, n)
+		skipped []*Element
+	)
+	for i := start; ; {
+		elem := v.owner(i)
+		if !sliceContainsMember(res, elem) && !sliceContainsMember(skipped, elem) {
+			d, labelled := elem.Topology.domain(level)
+			if labelled && domains[d] {
+				skipped = append(skipped, elem)
+			} else {
+				if labelled {
+					domains[d] = true
+				}
+				res = append(res, elem)
+			}
+		}
+		if len(res) == n {
+			return res, nil
+		}
+		if i = (i + 1) % len(v.hashes); i == start {
+			break
+		}
+	}
+	// not enough domains: fall back to the closest skipped elements
+	for _, elem := range skipped {
+		if len(res) == n {
+			break
+		}
+		res = append(res, elem)
+	}
+	return res, nil
+}
@@ -0,0 +1,121 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+package consistent
+
+import (
+	"strconv"
+	"testing"
+)
+
+func newTopologyRing() *Consistent {
+	x := New()
+	for i := 0; i < 9; i++ {
+		x.AddWithTopology("node"+strconv.Itoa(i), i, Topology{
+			Region: "eu",
+			Zone:   "zone" + strconv.Itoa(i%3),
+			Rack:   "rack" + strconv.Itoa(i),
+		})
+	}
+	return x
+}
+
+func TestGetNDistinctZones(t *testing.T) {
+	x := newTopologyRing()
+	for i := 0; i < 200; i++ {
+		members, err := x.GetNDistinct(strconv.Itoa(i), 3, LevelZone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkNum(len(members), 3, t)
+		zones := make(map[string]bool)
+		for _, m := range members {
+			zones[m.Topology.Zone] = true
+		}
+		if len(zones) != 3 {
+			t.Errorf("%d: expected 3 zones, got %v", i, zones)
+		}
+		first, _ := x.Get(strconv.Itoa(i))
+		if members[0] != first {
+			t.Errorf("%d: expected Get to come first", i)
+		}
+	}
+}
+
+func TestGetNDistinctFallback(t *testing.T) {
+	x := newTopologyRing()
+	members, err := x.GetNDistinct("abc", 5, LevelZone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkNum(len(members), 5, t)
+	zones := make(map[string]bool)
+	seen := make(map[*Element]bool)
+	for i, m := range members {
+		if seen[m] {
+			t.Errorf("duplicate %q", m.Key)
+		}
+		seen[m] = true
+		if i < 3 {
+			zones[m.Topology.Zone] = true
+		}
+	}
+	if len(zones) != 3 {
+		t.Errorf("expected the first 3 elements in distinct zones, got %v", zones)
+	}
+
+	// a single region is a single domain
+	members, err = x.GetNDistinct("abc", 3, LevelRegion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := x.GetN("abc", 3)
+	for i := range want {
+		if members[i] != want[i] {
+			t.Errorf("expected GetN order when domains run out")
+		}
+	}
+
+	members, err = x.GetNDistinct("abc", 20, LevelRack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkNum(len(members), 9, t)
+}
+
+func TestTopologyLabels(t *testing.T) {
+	x := New()
+	x.Add("abc", 1)
+	x.Add("def", 2)
+	// unlabelled elements are their own domains
+	members, err := x.GetNDistinct("abc", 2, LevelZone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkNum(len(members), 2, t)
+
+	if x.SetTopology("xyz", Topology{Zone: "a"}) {
+		t.Errorf("expected unknown element")
+	}
+	if !x.SetTopology("abc", Topology{Zone: "a"}) || !x.SetTopology("def", Topology{Zone: "b"}) {
+		t.Errorf("expected known elements")
+	}
+	x.Add("abc", 3)
+	x.Set(map[string]interface{}{"abc": 4, "def": 5})
+	m := x.Members()
+	if m["abc"].(*Element).Topology.Zone != "a" || m["def"].(*Element).Topology.Zone != "b" {
+		t.Errorf("expected labels to survive re-adding")
+	}
+	x.Remove("abc")
+	x.Add("abc", 6)
+	if x.Members()["abc"].(*Element).Topology.Zone != "" {
+		t.Errorf("expected Remove to drop labels")
+	}
+	if _, err := New().GetNDistinct("abc", 2, LevelZone); err != ErrEmptyCircle {
+		t.Errorf("expected empty circle error")
+	}
+	s := x.Snapshot()
+	members, _ = s.GetNDistinct("abc", 2, LevelRack)
+	checkNum(len(members), 2, t)
+}
@@ -0,0 +1,169 @@
+// Copyright (C) 2019 zhvala.
+// Use of this source code is governed by an MIT-style license
+// that can be found in the LICENSE file.
+
+package consistent
+
+import (
+	"sort"
+)
+
+// view is an immutable view of the circle. Writers build a new view after
+// every change and publish it atomically, so readers never take a lock.
+type view struct {
+	hashes  uints         // sorted points on the circle
+	owners  []int32       // elems[owners[i]] owns hashes[i]
+	elems   []*Element    // members by slot, nil for free slots
+	loads   []*memberLoad // loads by slot
+	count   int           // number of members
+	version uint64
+	// inclusive makes keys hashing onto a point belong to its owner
+	inclusive bool
+}
+
+// emptyView is the view of a Consistent object nothing was published to.
+var emptyView = &view{}
+
+// load returns the latest published view.
+func (c *Consistent) load() *view {
+	if v, ok := c.view.Load().(*view); ok {
+		return v
+	}
+	return emptyView
+}
+
+// publish builds a view from the current state and makes it visible to readers.
+// Owners of the points untouched since the last publish are carried over from
+// the previous view, so only the points in c.dirty need a lookup, unless the
+// circle was rebuilt.
+// need c.mu.Lock() before calling
+func (c *Consistent) publish() {
+	prev := c.load()
+	v := &view{
+		hashes:    c.sortedHashes,
+		owners:    make([]int32, len(c.sortedHashes)),
+		elems:     append([]*Element(nil), c.elems...),
+		loads:     append([]*memberLoad(nil), c.counters...),
+		inclusive: c.scheme.Inclusive(),
+	}
+	if c.rebuild {
+		for i, h := range v.hashes {
+			v.owners[i] = c.slots[c.circle[h]]
+		}
+	} else {
+		sort.Sort(c.dirty)
+		i, j := 0, 0
+		for k, h := range c.dirty {
+			if k > 0 && c.dirty[k-1] == h {
+				continue
+			}
+			// no point changed between i and h, so the owners of that run
+			// are the ones of the previous view
+			n := i + sort.Search(len(v.hashes)-i, func(x int) bool { return v.hashes[i+x] >= h })
+			m := j + copy(v.owners[i:n], prev.owners[j:])
+			i, j = n, m
+			if i < len(v.hashes) && v.hashes[i] == h {
+				v.owners[i] = c.slots[c.circle[h]]
+				i++
+			}
+			if j < len(prev.hashes) && prev.hashes[j] == h {
+				j++
+			}
+		}
+		copy(v.owners[i:], prev.owners[j:])
+	}
+	v.count = len(c.members)
+	c.dirty = c.dirty[:0]
+	c.rebuild = false
+	c.version++
+	v.version = c.version
+	c.view.Store(v)
+}
+
+// owner returns the owner of hashes[i].
+func (v *view) owner(i int) *Element {
+	return v.elems[v.owners[i]]
+}
+
+func (v *view) search(key uint64) int {
+	i := sort.Search(len(v.hashes), func(x int) bool {
+		return v.hashes[x] > key || v.inclusive && v.hashes[x] == key
+	})
+	if i >= len(v.hashes) {
+		i = 0
+	}
+	return i
+}
+
+func (v *view) get(key uint64) (*Element, error) {
+	if len(v.hashes) == 0 {
+		return nil, ErrEmptyCircle
+	}
+	return v.owner(v.search(key)), nil
+}
+
+func (v *view) getTwo(key uint64) (*Element, *Element, error) {
+	if len(v.hashes) == 0 {
+		return nil, nil, ErrEmptyCircle
+	}
+	start := v.search(key)
+	first := v.owner(start)
+	if v.count == 1 {
+		return first, nil, nil
+	}
+
+	var second *Element
+	for i := (start + 1) % len(v.hashes); i != start; i = (i + 1) % len(v.hashes) {
+		second = v.owner(i)
+		if second != first {
+			break
+		}
+	}
+	return first, second, nil
+}
+
+func (v *view) getN(key uint64, n int) ([]*Element, error) {
+	if len(v.hashes) == 0 {
+		return nil, ErrEmptyCircle
+	}
+	if v.count < n {
+		n = v.count
+	}
+	if n < 0 {
+		n = 0
+	}
+	return v.appendN(make([]*Element, 0, n), key, n)
+}
+
+// appendN appends the N closest distinct elements to key to res.
+func (v *view) appendN(res []*Element, key uint64, n int) ([]*Element, error) {
+	if len(v.hashes) == 0 {
+		return res, ErrEmptyCircle
+	}
+	if v.count < n {
+		n = v.count
+	}
+	if n <= 0 {
+		return res, nil
+	}
+
+	start := v.search(key)
+	res = append(res, v.owner(start))
+	for i := (start + 1) % len(v.hashes); i != start && len(res) < n; i = (i + 1) % len(v.hashes) {
+		elem := v.owner(i)
+		if !sliceContainsMember(res, elem) {
+			res = append(res, elem)
+		}
+	}
+
+	return res, nil
+}
+
+func sliceContainsMember(set []*Element, member *Element) bool {
+	for _, m := range set {
+		if m == member {
+			return true
+		}
+	}
+	return false
+}